@@ -0,0 +1,168 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+)
+
+const (
+	CONTENT_TYPE = "application/problem+json"
+)
+
+// machine readable codes sent to the clients
+const (
+	CodeInvalidJSON  = "invalid_json"
+	CodeInvalidParam = "invalid_param"
+	CodeValidation   = "validation_failed"
+	CodeUnauthorized = "unauthorized"
+	CodeInvalidToken = "invalid_token"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodePrecondition = "precondition_failed"
+	CodeIfMatch      = "if_match_required"
+	CodeMediaType    = "unsupported_media_type"
+	CodeTooLarge     = "payload_too_large"
+	CodeInternal     = "internal_error"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is the error returned by the handlers, only Status, Code, Message and Fields
+// are sent to the client, Cause is logged but never exposed
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  []FieldError
+	Cause   error
+}
+
+func New(status int, code string, message string) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithCause returns a copy of the error with the internal error that originated it
+func (e *Error) WithCause(cause error) *Error {
+	copied := *e
+	copied.Cause = cause
+	return &copied
+}
+
+// WithField returns a copy of the error with one more invalid field
+func (e *Error) WithField(field string, message string) *Error {
+	copied := *e
+	copied.Fields = append(append([]FieldError{}, e.Fields...), FieldError{Field: field, Message: message})
+	return &copied
+}
+
+func BadRequest(code string, message string) *Error {
+	return New(http.StatusBadRequest, code, message)
+}
+
+// InvalidJSON is returned when the body of the request can't be decoded
+func InvalidJSON(cause error) *Error {
+	return BadRequest(CodeInvalidJSON, "The request body is not valid JSON").WithCause(cause)
+}
+
+// InvalidParam is returned when a query or path param has a wrong value
+func InvalidParam(param string, message string) *Error {
+	return BadRequest(CodeInvalidParam, "Invalid value for "+param).WithField(param, message)
+}
+
+func Validation(fields ...FieldError) *Error {
+	err := New(http.StatusUnprocessableEntity, CodeValidation, "The request has invalid fields")
+	err.Fields = fields
+	return err
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func InvalidToken(cause error) *Error {
+	return New(http.StatusUnauthorized, CodeInvalidToken, "The token is missing, invalid or expired").WithCause(cause)
+}
+
+func Internal(cause error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "Something went wrong, try again later").WithCause(cause)
+}
+
+// From converts any error into an *Error, the errors of the repository are mapped
+// to their status and every unknown error is treated as an internal one
+func From(err error) *Error {
+	var apiErr *Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, repository.ErrNotFound):
+		return New(http.StatusNotFound, CodeNotFound, "The resource was not found").WithCause(err)
+	case errors.Is(err, repository.ErrForbidden):
+		return New(http.StatusForbidden, CodeForbidden, "You are not allowed to do this").WithCause(err)
+	case errors.Is(err, repository.ErrConflict):
+		return New(http.StatusConflict, CodeConflict, "The resource already exists").WithCause(err)
+	case errors.Is(err, repository.ErrPreconditionFailed):
+		return New(http.StatusPreconditionFailed, CodePrecondition, "The resource was modified, get it again and retry").WithCause(err)
+	default:
+		return Internal(err)
+	}
+}
+
+// problem is the RFC 7807 representation of an error
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Write logs the error and sends it to the client as application/problem+json
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := From(err)
+	requestID := requestctx.RequestID(r.Context())
+
+	if apiErr.Cause != nil || apiErr.Status >= http.StatusInternalServerError {
+		log.Printf("request %s %s %s: %d %v", requestID, r.Method, r.URL.Path, apiErr.Status, apiErr)
+	}
+
+	w.Header().Set("Content-Type", CONTENT_TYPE)
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(apiErr.Status),
+		Status:    apiErr.Status,
+		Detail:    apiErr.Message,
+		Instance:  r.URL.Path,
+		Code:      apiErr.Code,
+		RequestID: requestID,
+		Errors:    apiErr.Fields,
+	})
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+)
+
+// authorNotHidden is the condition that removes the posts or comments of the authors blocked
+// or muted by the viewer, both params are SQL expressions
+func authorNotHidden(viewer string, author string) string {
+	return fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM blocks WHERE blocks.blocker_id = %[1]s AND blocks.blocked_id = %[2]s)
+		AND NOT EXISTS (SELECT 1 FROM mutes WHERE mutes.muter_id = %[1]s AND mutes.muted_id = %[2]s)`, viewer, author)
+}
+
+// Block also removes the follows between both users, in the same transaction
+func (repo *PostgresRepository) Block(ctx context.Context, blockerId string, blockedId string) error {
+	return repo.withTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", blockerId, blockedId)
+		if isForeignKeyViolation(err) {
+			return repository.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM follows WHERE (follower_id = $1 AND followee_id = $2)
+			OR (follower_id = $2 AND followee_id = $1)`, blockerId, blockedId)
+		return err
+	})
+}
+
+func (repo *PostgresRepository) Unblock(ctx context.Context, blockerId string, blockedId string) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2", blockerId, blockedId)
+	return err
+}
+
+func (repo *PostgresRepository) IsBlocked(ctx context.Context, blockerId string, blockedId string) (bool, error) {
+	var blocked bool
+	err := repo.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM blocks WHERE blocker_id = $1 AND blocked_id = $2)", blockerId, blockedId).Scan(&blocked)
+	return blocked, err
+}
+
+func (repo *PostgresRepository) Mute(ctx context.Context, muterId string, mutedId string) error {
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO mutes (muter_id, muted_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", muterId, mutedId)
+	if isForeignKeyViolation(err) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+func (repo *PostgresRepository) Unmute(ctx context.Context, muterId string, mutedId string) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2", muterId, mutedId)
+	return err
+}
+
+func (repo *PostgresRepository) ListBlockedUsers(ctx context.Context, query *models.UserListQuery) ([]*models.RelatedUser, error) {
+	return repo.listRelatedUsers(ctx, "blocks", "blocker_id", "blocked_id", query)
+}
+
+func (repo *PostgresRepository) ListMutedUsers(ctx context.Context, query *models.UserListQuery) ([]*models.RelatedUser, error) {
+	return repo.listRelatedUsers(ctx, "mutes", "muter_id", "muted_id", query)
+}
+
+// listRelatedUsers lists the users of listedColumn related to query.UserId, the table and columns are never user input
+func (repo *PostgresRepository) listRelatedUsers(ctx context.Context, table string, userColumn string, listedColumn string, query *models.UserListQuery) ([]*models.RelatedUser, error) {
+	args := []interface{}{query.UserId}
+	statement := fmt.Sprintf(`SELECT %[1]s.%[3]s, COALESCE(users.username, ''), %[1]s.created_at FROM %[1]s
+		JOIN users ON users.id = %[1]s.%[3]s WHERE %[1]s.%[2]s = $1`, table, userColumn, listedColumn)
+
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.CreatedAt, query.Cursor.Id)
+		statement += fmt.Sprintf(" AND (%[1]s.created_at, %[1]s.%[2]s) < ($%[3]d, $%[4]d)", table, listedColumn, len(args)-1, len(args))
+	}
+
+	args = append(args, query.Limit)
+	statement += fmt.Sprintf(" ORDER BY %[1]s.created_at DESC, %[1]s.%[2]s DESC LIMIT $%[3]d", table, listedColumn, len(args))
+
+	return repo.queryRelatedUsers(ctx, statement, args...)
+}
+
+// ListHidingUserIds returns the users that blocked or muted the user, they don't receive its events
+func (repo *PostgresRepository) ListHidingUserIds(ctx context.Context, userId string) ([]string, error) {
+	rows, err := repo.db.QueryContext(ctx, `SELECT blocker_id FROM blocks WHERE blocked_id = $1
+		UNION SELECT muter_id FROM mutes WHERE muted_id = $1`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIds []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIds = append(userIds, id)
+	}
+	return userIds, rows.Err()
+}
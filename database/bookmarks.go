@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/lib/pq"
+)
+
+// columns of the collections read by scanCollection
+const collectionColumns = `bookmark_collections.id, bookmark_collections.user_id, bookmark_collections.name, bookmark_collections.created_at,
+	(SELECT COUNT(*) FROM bookmarks WHERE bookmarks.collection_id = bookmark_collections.id)`
+
+func scanCollection(row scanner, collection *models.BookmarkCollection) error {
+	return row.Scan(&collection.Id, &collection.UserId, &collection.Name, &collection.CreatedAt, &collection.BookmarkCount)
+}
+
+// InsertCollection returns ErrConflict when the user already has a collection with the same name
+func (repo *PostgresRepository) InsertCollection(ctx context.Context, collection *models.BookmarkCollection) error {
+	err := repo.db.QueryRowContext(ctx, "INSERT INTO bookmark_collections (id, user_id, name) VALUES ($1, $2, $3) RETURNING created_at",
+		collection.Id, collection.UserId, collection.Name).Scan(&collection.CreatedAt)
+	if isUniqueViolation(err) {
+		return repository.ErrConflict
+	}
+	return err
+}
+
+// GetCollection returns ErrNotFound for the collections of other users
+func (repo *PostgresRepository) GetCollection(ctx context.Context, userId string, id string) (*models.BookmarkCollection, error) {
+	var collection = models.BookmarkCollection{}
+
+	err := scanCollection(repo.db.QueryRowContext(ctx, "SELECT "+collectionColumns+" FROM bookmark_collections WHERE id = $1 AND user_id = $2", id, userId), &collection)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+func (repo *PostgresRepository) RenameCollection(ctx context.Context, userId string, id string, name string) (*models.BookmarkCollection, error) {
+	var collection = models.BookmarkCollection{}
+
+	err := scanCollection(repo.db.QueryRowContext(ctx, "UPDATE bookmark_collections SET name = $1 WHERE id = $2 AND user_id = $3 RETURNING "+collectionColumns,
+		name, id, userId), &collection)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if isUniqueViolation(err) {
+		return nil, repository.ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// DeleteCollection removes the collection with all its bookmarks
+func (repo *PostgresRepository) DeleteCollection(ctx context.Context, userId string, id string) error {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM bookmark_collections WHERE id = $1 AND user_id = $2", id, userId)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) ListCollections(ctx context.Context, query *models.CollectionQuery) ([]*models.BookmarkCollection, error) {
+	args := []interface{}{query.UserId}
+	statement := "SELECT " + collectionColumns + " FROM bookmark_collections WHERE bookmark_collections.user_id = $1"
+
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.CreatedAt, query.Cursor.Id)
+		statement += fmt.Sprintf(" AND (bookmark_collections.created_at, bookmark_collections.id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+
+	args = append(args, query.Limit)
+	statement += fmt.Sprintf(" ORDER BY bookmark_collections.created_at DESC, bookmark_collections.id DESC LIMIT $%d", len(args))
+
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []*models.BookmarkCollection
+	for rows.Next() {
+		var collection = models.BookmarkCollection{}
+		if err := scanCollection(rows, &collection); err != nil {
+			return nil, err
+		}
+		collections = append(collections, &collection)
+	}
+	return collections, rows.Err()
+}
+
+// AddBookmark is idempotent, saving a post twice in the same collection keeps the first bookmark
+func (repo *PostgresRepository) AddBookmark(ctx context.Context, collectionId string, postId string) error {
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO bookmarks (collection_id, post_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", collectionId, postId)
+	if isForeignKeyViolation(err) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+func (repo *PostgresRepository) RemoveBookmark(ctx context.Context, collectionId string, postId string) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM bookmarks WHERE collection_id = $1 AND post_id = $2", collectionId, postId)
+	return err
+}
+
+func (repo *PostgresRepository) ListBookmarks(ctx context.Context, query *models.BookmarkQuery) ([]*models.Bookmark, error) {
+	args := []interface{}{query.CollectionId}
+	statement := "SELECT collection_id, post_id, created_at FROM bookmarks WHERE collection_id = $1"
+
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.CreatedAt, query.Cursor.Id)
+		statement += fmt.Sprintf(" AND (created_at, post_id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+
+	args = append(args, query.Limit)
+	statement += fmt.Sprintf(" ORDER BY created_at DESC, post_id DESC LIMIT $%d", len(args))
+
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bookmarks []*models.Bookmark
+	for rows.Next() {
+		var bookmark = models.Bookmark{}
+		if err := rows.Scan(&bookmark.CollectionId, &bookmark.PostId, &bookmark.CreatedAt); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, &bookmark)
+	}
+	return bookmarks, rows.Err()
+}
+
+// GetPostsByIds returns the posts that exist, are not deleted and the viewer can see, in any order.
+// Like in the listings, the posts of the authors blocked or muted by the viewer are left out
+func (repo *PostgresRepository) GetPostsByIds(ctx context.Context, ids []string, viewerId string) ([]*models.Post, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	statement := "SELECT " + postColumns + " FROM posts WHERE posts.id = ANY($1) AND posts.deleted_at IS NULL AND " +
+		fmt.Sprintf(postVisibleCondition, "$2") + " AND " + authorNotHidden("$2", "posts.user_id")
+	return repo.queryPosts(ctx, statement, pq.Array(ids), viewerId)
+}
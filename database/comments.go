@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/lib/pq"
+)
+
+// columns of the comments read by every query, in the order expected by scanComment
+const commentColumns = `comments.id, comments.post_id, comments.parent_id, comments.user_id, comments.comment_content,
+	comments.comment_html, comments.created_at,
+	(SELECT COUNT(*) FROM comments replies WHERE replies.parent_id = comments.id)`
+
+func scanComment(row scanner, comment *models.Comment, extra ...interface{}) error {
+	var parentId sql.NullString
+	dest := []interface{}{&comment.Id, &comment.PostId, &parentId, &comment.UserId, &comment.CommentContent, &comment.CommentHtml, &comment.CreatedAt, &comment.ReplyCount}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if parentId.Valid {
+		comment.ParentId = &parentId.String
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) InsertComment(ctx context.Context, comment *models.Comment) error {
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO comments (id, post_id, parent_id, user_id, comment_content, comment_html) VALUES ($1, $2, $3, $4, $5, $6)",
+		comment.Id, comment.PostId, comment.ParentId, comment.UserId, comment.CommentContent, comment.CommentHtml)
+	return err
+}
+
+func (repo *PostgresRepository) GetCommentById(ctx context.Context, postId string, id string) (*models.Comment, error) {
+	var comment = models.Comment{}
+
+	err := scanComment(repo.db.QueryRowContext(ctx, "SELECT "+commentColumns+" FROM comments WHERE post_id = $1 AND id = $2", postId, id), &comment)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (repo *PostgresRepository) UpdateComment(ctx context.Context, comment *models.Comment) error {
+	result, err := repo.db.ExecContext(ctx, "UPDATE comments SET comment_content = $1, comment_html = $2 WHERE post_id = $3 AND id = $4 AND user_id = $5",
+		comment.CommentContent, comment.CommentHtml, comment.PostId, comment.Id, comment.UserId)
+	if err != nil {
+		return err
+	}
+	return repo.checkCommentOwnership(ctx, result, comment.PostId, comment.Id)
+}
+
+// DeleteComment removes the comment and, because of the foreign key, all of its replies
+func (repo *PostgresRepository) DeleteComment(ctx context.Context, postId string, id string, userId string) error {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM comments WHERE post_id = $1 AND id = $2 AND user_id = $3", postId, id, userId)
+	if err != nil {
+		return err
+	}
+	return repo.checkCommentOwnership(ctx, result, postId, id)
+}
+
+func (repo *PostgresRepository) checkCommentOwnership(ctx context.Context, result sql.Result, postId string, id string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := repo.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM comments WHERE post_id = $1 AND id = $2)", postId, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return repository.ErrForbidden
+	}
+	return repository.ErrNotFound
+}
+
+// ListComments returns a page of comments of the same level, oldest first
+func (repo *PostgresRepository) ListComments(ctx context.Context, query *models.CommentQuery) ([]*models.Comment, error) {
+	args := []interface{}{query.PostId, query.ViewerId}
+	statement := "SELECT " + commentColumns + " FROM comments WHERE comments.post_id = $1 AND " + authorNotHidden("$2", "comments.user_id")
+
+	if query.ParentId == "" {
+		statement += " AND comments.parent_id IS NULL"
+	} else {
+		args = append(args, query.ParentId)
+		statement += fmt.Sprintf(" AND comments.parent_id = $%d", len(args))
+	}
+
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.CreatedAt, query.Cursor.Id)
+		statement += fmt.Sprintf(" AND (comments.created_at, comments.id) > ($%d, $%d)", len(args)-1, len(args))
+	}
+
+	args = append(args, query.Limit)
+	statement += fmt.Sprintf(" ORDER BY comments.created_at, comments.id LIMIT $%d", len(args))
+
+	return repo.queryComments(ctx, statement, args...)
+}
+
+// ListCommentReplies returns, in a single query, the replies of the parents up to depth levels below them.
+// The replies of the users hidden by the viewer are skipped together with the replies below them
+func (repo *PostgresRepository) ListCommentReplies(ctx context.Context, parentIds []string, depth int, viewerId string) ([]*models.Comment, error) {
+	if len(parentIds) == 0 || depth <= 0 {
+		return nil, nil
+	}
+
+	statement := `WITH RECURSIVE tree AS (
+			SELECT id, 1 AS depth FROM comments WHERE parent_id = ANY($1) AND ` + authorNotHidden("$3", "comments.user_id") + `
+			UNION ALL
+			SELECT comments.id, tree.depth + 1 FROM comments JOIN tree ON comments.parent_id = tree.id
+			WHERE tree.depth < $2 AND ` + authorNotHidden("$3", "comments.user_id") + `
+		)
+		SELECT ` + commentColumns + ` FROM comments JOIN tree ON tree.id = comments.id
+		ORDER BY comments.created_at, comments.id`
+
+	return repo.queryComments(ctx, statement, pq.Array(parentIds), depth, viewerId)
+}
+
+func (repo *PostgresRepository) queryComments(ctx context.Context, statement string, args ...interface{}) ([]*models.Comment, error) {
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	var comments []*models.Comment
+
+	for rows.Next() {
+		var comment = models.Comment{}
+		if err = scanComment(rows, &comment); err == nil {
+			comments = append(comments, &comment)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// CountComments returns the number of comments of every post, the posts without comments are not in the map
+func (repo *PostgresRepository) CountComments(ctx context.Context, postIds []string) (map[string]int, error) {
+	counts := map[string]int{}
+	if len(postIds) == 0 {
+		return counts, nil
+	}
+
+	rows, err := repo.db.QueryContext(ctx, "SELECT post_id, COUNT(*) FROM comments WHERE post_id = ANY($1) GROUP BY post_id", pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	for rows.Next() {
+		var postId string
+		var count int
+		if err = rows.Scan(&postId, &count); err == nil {
+			counts[postId] = count
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
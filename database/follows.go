@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+)
+
+// Follow is idempotent, following a user twice keeps the first follow. It returns ErrNotFound
+// when the followed user doesn't exist
+func (repo *PostgresRepository) Follow(ctx context.Context, followerId string, followeeId string) error {
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO follows (follower_id, followee_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", followerId, followeeId)
+	if isForeignKeyViolation(err) {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+func (repo *PostgresRepository) Unfollow(ctx context.Context, followerId string, followeeId string) error {
+	_, err := repo.db.ExecContext(ctx, "DELETE FROM follows WHERE follower_id = $1 AND followee_id = $2", followerId, followeeId)
+	return err
+}
+
+func (repo *PostgresRepository) ListFollows(ctx context.Context, query *models.FollowQuery) ([]*models.RelatedUser, error) {
+	// the listed users are on the other side of the relation
+	userColumn, listedColumn := "follows.followee_id", "follows.follower_id"
+	if query.Following {
+		userColumn, listedColumn = listedColumn, userColumn
+	}
+
+	args := []interface{}{query.UserId}
+	statement := fmt.Sprintf(`SELECT %[2]s, COALESCE(users.username, ''), follows.created_at FROM follows
+		JOIN users ON users.id = %[2]s WHERE %[1]s = $1`, userColumn, listedColumn)
+
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.CreatedAt, query.Cursor.Id)
+		statement += fmt.Sprintf(" AND (follows.created_at, %s) < ($%d, $%d)", listedColumn, len(args)-1, len(args))
+	}
+
+	args = append(args, query.Limit)
+	statement += fmt.Sprintf(" ORDER BY follows.created_at DESC, %s DESC LIMIT $%d", listedColumn, len(args))
+
+	return repo.queryRelatedUsers(ctx, statement, args...)
+}
+
+// queryRelatedUsers runs a statement that selects the id, username and the start of the relation
+func (repo *PostgresRepository) queryRelatedUsers(ctx context.Context, statement string, args ...interface{}) ([]*models.RelatedUser, error) {
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.RelatedUser
+	for rows.Next() {
+		var user = models.RelatedUser{}
+		if err := rows.Scan(&user.UserId, &user.Username, &user.CreatedAt); err != nil {
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	return users, rows.Err()
+}
+
+// ListFollowerIds returns the ids of all the followers of the user
+func (repo *PostgresRepository) ListFollowerIds(ctx context.Context, userId string) ([]string, error) {
+	rows, err := repo.db.QueryContext(ctx, "SELECT follower_id FROM follows WHERE followee_id = $1", userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var followerIds []string
+	for rows.Next() {
+		var followerId string
+		if err := rows.Scan(&followerId); err != nil {
+			return nil, err
+		}
+		followerIds = append(followerIds, followerId)
+	}
+	return followerIds, rows.Err()
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/lib/pq"
+)
+
+const mediaColumns = "media.id, media.user_id, media.post_id, media.storage_key, media.content_type, media.file_name, media.size, media.status, media.created_at"
+
+func scanMedia(row scanner, media *models.Media) error {
+	var postId sql.NullString
+	if err := row.Scan(&media.Id, &media.UserId, &postId, &media.StorageKey, &media.ContentType, &media.FileName, &media.Size, &media.Status, &media.CreatedAt); err != nil {
+		return err
+	}
+	if postId.Valid {
+		media.PostId = &postId.String
+	}
+	return nil
+}
+
+func (repo *PostgresRepository) queryMedia(ctx context.Context, statement string, args ...interface{}) ([]*models.Media, error) {
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list = []*models.Media{}
+	for rows.Next() {
+		var media = models.Media{}
+		if err := scanMedia(rows, &media); err != nil {
+			return nil, err
+		}
+		list = append(list, &media)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, repo.loadVariants(ctx, list)
+}
+
+// loadVariants reads the variants of all the media in a single query
+func (repo *PostgresRepository) loadVariants(ctx context.Context, list []*models.Media) error {
+	byId := map[string]*models.Media{}
+	ids := make([]string, 0, len(list))
+	for _, media := range list {
+		media.Variants = []models.MediaVariant{}
+		if media.Status == models.MediaStatusReady {
+			byId[media.Id] = media
+			ids = append(ids, media.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	rows, err := repo.db.QueryContext(ctx, `SELECT media_id, name, storage_key, content_type, width, height, size
+		FROM media_variants WHERE media_id = ANY($1) ORDER BY media_id, width`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mediaId string
+		var variant = models.MediaVariant{}
+		if err := rows.Scan(&mediaId, &variant.Name, &variant.StorageKey, &variant.ContentType, &variant.Width, &variant.Height, &variant.Size); err != nil {
+			return err
+		}
+		byId[mediaId].Variants = append(byId[mediaId].Variants, variant)
+	}
+	return rows.Err()
+}
+
+func (repo *PostgresRepository) InsertMedia(ctx context.Context, media *models.Media) error {
+	return repo.db.QueryRowContext(ctx, `INSERT INTO media (id, user_id, storage_key, content_type, file_name, size, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at`,
+		media.Id, media.UserId, media.StorageKey, media.ContentType, media.FileName, media.Size, media.Status).Scan(&media.CreatedAt)
+}
+
+func (repo *PostgresRepository) GetMediaById(ctx context.Context, id string) (*models.Media, error) {
+	list, err := repo.queryMedia(ctx, "SELECT "+mediaColumns+" FROM media WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return list[0], nil
+}
+
+// PurgeUnattachedMedia removes the uploads created before the date that were never attached to a post,
+// it returns their storage keys so the caller deletes the objects. They have no variants, only the
+// attached media are processed
+func (repo *PostgresRepository) PurgeUnattachedMedia(ctx context.Context, createdBefore time.Time) ([]string, error) {
+	rows, err := repo.db.QueryContext(ctx, "DELETE FROM media WHERE post_id IS NULL AND created_at < $1 RETURNING storage_key", createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var storageKeys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		storageKeys = append(storageKeys, key)
+	}
+	return storageKeys, rows.Err()
+}
+
+// attachMedia attaches the media of the user to the post in the order of the ids. It fails with
+// ErrConflict when one isn't of the user or is already attached, the caller rolls back the transaction
+func attachMedia(ctx context.Context, tx *sql.Tx, postId string, userId string, mediaIds []string) error {
+	if len(mediaIds) == 0 {
+		return nil
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE media SET post_id = $1, position = ARRAY_POSITION($3::VARCHAR[], id)
+		WHERE id = ANY($3) AND user_id = $2 AND post_id IS NULL`, postId, userId, pq.Array(mediaIds))
+	if err != nil {
+		return err
+	}
+	attached, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if attached != int64(len(mediaIds)) {
+		return repository.ErrConflict
+	}
+	return nil
+}
+
+// ListPostMedia returns the media of every post in their order, the posts without media are not in the map
+func (repo *PostgresRepository) ListPostMedia(ctx context.Context, postIds []string) (map[string][]*models.Media, error) {
+	byPost := map[string][]*models.Media{}
+	if len(postIds) == 0 {
+		return byPost, nil
+	}
+
+	list, err := repo.queryMedia(ctx, "SELECT "+mediaColumns+" FROM media WHERE post_id = ANY($1) ORDER BY post_id, position", pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	for _, media := range list {
+		byPost[*media.PostId] = append(byPost[*media.PostId], media)
+	}
+	return byPost, nil
+}
+
+// ClaimPendingMedia marks as processing the attached media whose variants have to be generated and returns them.
+// The ones that are processing for longer than staleAfter are claimed again, the server that had them may have stopped.
+// The rows are locked with SKIP LOCKED so every media is processed by only one replica
+func (repo *PostgresRepository) ClaimPendingMedia(ctx context.Context, limit int, staleAfter time.Duration) ([]*models.Media, error) {
+	return repo.queryMedia(ctx, `UPDATE media SET status = 'processing', processing_started_at = NOW()
+		WHERE id IN (
+			SELECT id FROM media WHERE post_id IS NOT NULL
+				AND (status = 'pending' OR (status = 'processing' AND processing_started_at < NOW() - MAKE_INTERVAL(secs => $2)))
+			ORDER BY created_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+mediaColumns, limit, staleAfter.Seconds())
+}
+
+// SaveMediaVariants replaces the variants of the media and marks it as ready
+func (repo *PostgresRepository) SaveMediaVariants(ctx context.Context, mediaId string, variants []models.MediaVariant) error {
+	return repo.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM media_variants WHERE media_id = $1", mediaId); err != nil {
+			return err
+		}
+		for _, variant := range variants {
+			_, err := tx.ExecContext(ctx, `INSERT INTO media_variants (media_id, name, storage_key, content_type, width, height, size)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)`, mediaId, variant.Name, variant.StorageKey, variant.ContentType, variant.Width, variant.Height, variant.Size)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE media SET status = 'ready', processing_started_at = NULL WHERE id = $1", mediaId)
+		return err
+	})
+}
+
+func (repo *PostgresRepository) SetMediaStatus(ctx context.Context, mediaId string, status models.MediaStatus) error {
+	_, err := repo.db.ExecContext(ctx, "UPDATE media SET status = $2, processing_started_at = NULL WHERE id = $1", mediaId, status)
+	return err
+}
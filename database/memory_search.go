@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/search"
+)
+
+const (
+	MEMORY_SNIPPET_WORDS = 30
+)
+
+// MemoryPostSearcher is a simple in memory implementation of repository.PostSearcher,
+// it doesn't do any stemming so it is only meant to be used in tests
+type MemoryPostSearcher struct {
+	mu    sync.RWMutex
+	posts map[string]*models.Post
+}
+
+func NewMemoryPostSearcher(posts ...*models.Post) *MemoryPostSearcher {
+	searcher := &MemoryPostSearcher{
+		posts: map[string]*models.Post{},
+	}
+	for _, post := range posts {
+		searcher.Index(post)
+	}
+	return searcher
+}
+
+// Index adds the post to the searcher or replaces the previous version of it
+func (m *MemoryPostSearcher) Index(post *models.Post) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *post
+	m.posts[post.Id] = &copied
+}
+
+func (m *MemoryPostSearcher) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.posts, id)
+}
+
+// span of a word inside of the content of a post
+type wordSpan struct {
+	word       string
+	start, end int
+}
+
+func splitSpans(content string) []wordSpan {
+	var spans []wordSpan
+	start := -1
+	for i, r := range content {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			spans = append(spans, wordSpan{strings.ToLower(content[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{strings.ToLower(content[start:]), start, len(content)})
+	}
+	return spans
+}
+
+func matchesWord(term search.Term, i int, word string) bool {
+	expected := term.Words[i]
+	if term.Prefix && i == len(term.Words)-1 {
+		return strings.HasPrefix(word, expected)
+	}
+	return word == expected
+}
+
+// matchTerm returns the indexes of the spans where the term starts
+func matchTerm(term search.Term, spans []wordSpan) []int {
+	var matches []int
+	for i := 0; i+len(term.Words) <= len(spans); i++ {
+		matched := true
+		for j := range term.Words {
+			if !matchesWord(term, j, spans[i+j].word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+func (m *MemoryPostSearcher) SearchPosts(ctx context.Context, query *models.PostSearchQuery) ([]*models.PostSearchResult, error) {
+	parsed := search.Parse(query.Query)
+	if parsed.IsEmpty() {
+		return nil, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var results []*models.PostSearchResult
+	for _, post := range m.posts {
+		spans := splitSpans(post.PostContent)
+		highlighted := make([]bool, len(spans))
+		total := 0
+		matchedAll := true
+
+		for _, term := range parsed.Terms {
+			matches := matchTerm(term, spans)
+			if len(matches) == 0 {
+				matchedAll = false
+				break
+			}
+			total += len(matches)
+			for _, start := range matches {
+				for j := range term.Words {
+					highlighted[start+j] = true
+				}
+			}
+		}
+
+		if !matchedAll {
+			continue
+		}
+
+		results = append(results, &models.PostSearchResult{
+			Post:    *post,
+			Rank:    float32(total) / float32(len(spans)),
+			Snippet: search.Highlight(memorySnippet(post.PostContent, spans, highlighted)),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Id > results[j].Id
+	})
+
+	if query.Cursor != nil {
+		position := sort.Search(len(results), func(i int) bool {
+			result := results[i]
+			return result.Rank < query.Cursor.Rank || (result.Rank == query.Cursor.Rank && result.Id < query.Cursor.Id)
+		})
+		results = results[position:]
+	}
+
+	if uint64(len(results)) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, nil
+}
+
+// memorySnippet returns the words around the first match, with the matches between markers
+func memorySnippet(content string, spans []wordSpan, highlighted []bool) string {
+	first := 0
+	for i, h := range highlighted {
+		if h {
+			first = i
+			break
+		}
+	}
+
+	from := first - MEMORY_SNIPPET_WORDS/2
+	if from < 0 {
+		from = 0
+	}
+	to := from + MEMORY_SNIPPET_WORDS
+	if to > len(spans) {
+		to = len(spans)
+	}
+
+	var builder strings.Builder
+	position := spans[from].start
+	for i := from; i < to; i++ {
+		builder.WriteString(content[position:spans[i].start])
+		if highlighted[i] {
+			builder.WriteString(search.StartMark + content[spans[i].start:spans[i].end] + search.StopMark)
+		} else {
+			builder.WriteString(content[spans[i].start:spans[i].end])
+		}
+		position = spans[i].end
+	}
+
+	return builder.String()
+}
+
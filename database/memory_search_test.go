@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+func newTestSearcher() *MemoryPostSearcher {
+	return NewMemoryPostSearcher(
+		&models.Post{Id: "1", PostContent: "The quick brown fox jumps over the lazy dog"},
+		&models.Post{Id: "2", PostContent: "A brown dog, quick to bark"},
+		&models.Post{Id: "3", PostContent: "Gophers write Go"},
+		&models.Post{Id: "4", PostContent: "go go go"},
+		&models.Post{Id: "5", PostContent: "<b>bold</b> & go"},
+	)
+}
+
+func searchIds(t *testing.T, searcher *MemoryPostSearcher, query *models.PostSearchQuery) []string {
+	t.Helper()
+
+	results, err := searcher.SearchPosts(context.Background(), query)
+	if err != nil {
+		t.Fatalf("SearchPosts(%q): %v", query.Query, err)
+	}
+	ids := []string{}
+	for _, result := range results {
+		ids = append(ids, result.Id)
+	}
+	return ids
+}
+
+func TestMemorySearchMatching(t *testing.T) {
+	searcher := newTestSearcher()
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"Word", "dog", []string{"2", "1"}},
+		{"EveryTermIsRequired", "quick dog", []string{"2", "1"}},
+		{"Phrase", `"quick brown"`, []string{"1"}},
+		{"PhraseInAnotherOrder", `"brown quick"`, []string{}},
+		{"Prefix", "goph*", []string{"3"}},
+		{"WithoutPrefix", "goph", []string{}},
+		{"RankedByMatchesPerWord", "go", []string{"4", "3", "5"}},
+		{"CaseInsensitive", "QUICK", []string{"2", "1"}},
+		{"Empty", `"" *`, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := searchIds(t, searcher, &models.PostSearchQuery{Query: test.query, Limit: 10})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("SearchPosts(%q) = %v, want %v", test.query, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMemorySearchSnippet(t *testing.T) {
+	searcher := newTestSearcher()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`"lazy dog"`, "The quick brown fox jumps over the <mark>lazy</mark> <mark>dog</mark>"},
+		{"bold", "b&gt;<mark>bold</mark>&lt;/b&gt; &amp; go"},
+	}
+
+	for _, test := range tests {
+		results, err := searcher.SearchPosts(context.Background(), &models.PostSearchQuery{Query: test.query, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("SearchPosts(%q) returned %d results, want 1", test.query, len(results))
+		}
+		if results[0].Snippet != test.want {
+			t.Errorf("snippet of %q = %q, want %q", test.query, results[0].Snippet, test.want)
+		}
+	}
+}
+
+func TestMemorySearchPagination(t *testing.T) {
+	searcher := newTestSearcher()
+	query := models.PostSearchQuery{Query: "go*", Limit: 1}
+
+	var pages []string
+	for len(pages) <= 5 {
+		results, err := searcher.SearchPosts(context.Background(), &query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 0 {
+			break
+		}
+		pages = append(pages, results[0].Id)
+
+		// the cursor goes through the client like in the handler
+		last := results[len(results)-1]
+		if query.Cursor, err = models.DecodeSearchCursor((&models.SearchCursor{Rank: last.Rank, Id: last.Id}).Encode()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := searchIds(t, searcher, &models.PostSearchQuery{Query: "go*", Limit: 10})
+	if !reflect.DeepEqual(pages, want) {
+		t.Errorf("the pages have %v, want %v", pages, want)
+	}
+}
+
+func TestMemorySearchIndex(t *testing.T) {
+	searcher := newTestSearcher()
+
+	searcher.Index(&models.Post{Id: "1", PostContent: "nothing about animals"})
+	if got := searchIds(t, searcher, &models.PostSearchQuery{Query: "fox", Limit: 10}); len(got) != 0 {
+		t.Errorf("the replaced post still matches: %v", got)
+	}
+
+	searcher.Remove("2")
+	if got := searchIds(t, searcher, &models.PostSearchQuery{Query: "dog", Limit: 10}); len(got) != 0 {
+		t.Errorf("the removed post still matches: %v", got)
+	}
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/lib/pq"
+)
+
+// insertPoll stores the poll of the new post with its options, in the order they have in the poll
+func insertPoll(ctx context.Context, tx *sql.Tx, poll *models.Poll) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO polls (post_id, multiple, closes_at) VALUES ($1, $2, $3)", poll.PostId, poll.Multiple, poll.ClosesAt)
+	if err != nil {
+		return err
+	}
+
+	for i, option := range poll.Options {
+		_, err := tx.ExecContext(ctx, "INSERT INTO poll_options (id, post_id, position, option_text) VALUES ($1, $2, $3, $4)", option.Id, poll.PostId, i, option.Text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPolls reads the polls of the posts with their tallies in a single query,
+// the posts without poll are not in the map
+func (repo *PostgresRepository) GetPolls(ctx context.Context, postIds []string, viewerId string) (map[string]*models.Poll, error) {
+	polls := map[string]*models.Poll{}
+	if len(postIds) == 0 {
+		return polls, nil
+	}
+
+	rows, err := repo.db.QueryContext(ctx, `SELECT polls.post_id, polls.multiple, polls.closes_at, polls.closed_at,
+			(SELECT COUNT(*) FROM poll_ballots ballots WHERE ballots.post_id = polls.post_id),
+			options.id, options.option_text,
+			(SELECT COUNT(*) FROM poll_votes votes WHERE votes.option_id = options.id),
+			EXISTS (SELECT 1 FROM poll_votes votes WHERE votes.option_id = options.id AND votes.user_id = $2)
+		FROM polls JOIN poll_options options ON options.post_id = polls.post_id
+		WHERE polls.post_id = ANY($1) ORDER BY polls.post_id, options.position`, pq.Array(postIds), viewerId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var poll = models.Poll{Options: []models.PollOption{}, Mine: []string{}}
+		var option = models.PollOption{}
+		var mine bool
+		if err := rows.Scan(&poll.PostId, &poll.Multiple, &poll.ClosesAt, &poll.ClosedAt, &poll.Voters, &option.Id, &option.Text, &option.Votes, &mine); err != nil {
+			return nil, err
+		}
+
+		current, ok := polls[poll.PostId]
+		if !ok {
+			current = &poll
+			polls[poll.PostId] = current
+		}
+		current.Options = append(current.Options, option)
+		if mine {
+			current.Mine = append(current.Mine, option.Id)
+		}
+	}
+	return polls, rows.Err()
+}
+
+// Vote stores the ballot of the user with the options chosen. It returns ErrConflict when the user
+// already voted or the poll was closed meanwhile, and ErrNotFound when an option isn't of the poll
+func (repo *PostgresRepository) Vote(ctx context.Context, postId string, userId string, optionIds []string) error {
+	return repo.withTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `INSERT INTO poll_ballots (post_id, user_id)
+			SELECT post_id, $2 FROM polls WHERE post_id = $1 AND closed_at IS NULL AND (closes_at IS NULL OR closes_at > NOW())`, postId, userId)
+		if isUniqueViolation(err) {
+			return repository.ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if inserted == 0 {
+			return repository.ErrConflict
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO poll_votes (post_id, user_id, option_id) SELECT $1, $2, UNNEST($3::VARCHAR[])", postId, userId, pq.Array(optionIds))
+		if isForeignKeyViolation(err) {
+			return repository.ErrNotFound
+		}
+		return err
+	})
+}
+
+// CloseDuePolls closes the polls whose closes_at is due and returns them with their final tallies.
+// The rows are locked with SKIP LOCKED so every poll is closed (and notified) by only one replica
+func (repo *PostgresRepository) CloseDuePolls(ctx context.Context, limit int) ([]*models.Poll, error) {
+	rows, err := repo.db.QueryContext(ctx, `UPDATE polls SET closed_at = closes_at
+		WHERE post_id IN (
+			SELECT post_id FROM polls WHERE closed_at IS NULL AND closes_at <= NOW()
+			ORDER BY closes_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		) AND closed_at IS NULL
+		RETURNING post_id`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var postIds []string
+	for rows.Next() {
+		var postId string
+		if err := rows.Scan(&postId); err != nil {
+			return nil, err
+		}
+		postIds = append(postIds, postId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tallies, err := repo.GetPolls(ctx, postIds, "")
+	if err != nil {
+		return nil, err
+	}
+
+	polls := make([]*models.Poll, 0, len(tallies))
+	for _, postId := range postIds {
+		if poll, ok := tallies[postId]; ok {
+			polls = append(polls, poll)
+		}
+	}
+	return polls, nil
+}
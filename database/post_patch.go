@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/lib/pq"
+)
+
+// buildPatchPostQuery only sets the columns present in the patch, the revision is always incremented
+func buildPatchPostQuery(id string, userId string, patch *models.PostPatch, expectedRevisions []int) (string, []interface{}) {
+	var sets []string
+	var args []interface{}
+
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if patch.PostContent != nil {
+		set("post_content", *patch.PostContent)
+	}
+	if patch.PostHtml != nil {
+		set("post_html", *patch.PostHtml)
+	}
+	if patch.Schedule != nil {
+		set("status", patch.Schedule.Status)
+		set("publish_at", patch.Schedule.PublishAt)
+		sets = append(sets, fmt.Sprintf("published_at = CASE WHEN $%d = 'published' THEN NOW() END", len(args)-1))
+	}
+	if patch.Visibility != nil {
+		set("visibility", *patch.Visibility)
+	}
+	sets = append(sets, "revision = revision + 1", "updated_at = NOW()")
+
+	args = append(args, id, userId, pq.Array(expectedRevisions))
+	statement := fmt.Sprintf(`UPDATE posts SET %s
+		WHERE id = $%d AND user_id = $%d AND deleted_at IS NULL AND (CARDINALITY($%d::INTEGER[]) = 0 OR revision = ANY($%d))
+		RETURNING %s`, strings.Join(sets, ", "), len(args)-2, len(args)-1, len(args), len(args), postColumns)
+
+	return statement, args
+}
+
+// PatchPost applies the patch and keeps the new version in the revisions, with the entities of the content
+// when it changed. It returns the post after the change
+func (repo *PostgresRepository) PatchPost(ctx context.Context, id string, userId string, patch *models.PostPatch, expectedRevisions []int) (*models.Post, error) {
+	var post = models.Post{}
+
+	statement, args := buildPatchPostQuery(id, userId, patch, expectedRevisions)
+	err := repo.withTx(ctx, func(tx *sql.Tx) error {
+		err := scanPost(tx.QueryRowContext(ctx, statement, args...), &post)
+		if err == sql.ErrNoRows {
+			return repo.postUpdateError(ctx, id, userId)
+		}
+		if err != nil {
+			return err
+		}
+		if err := insertPostRevision(ctx, tx, &post); err != nil {
+			return err
+		}
+		if patch.Entities == nil {
+			return nil
+		}
+		return setPostEntities(ctx, tx, post.Id, patch.Entities)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &post, nil
+}
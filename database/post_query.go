@@ -0,0 +1,72 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+// escape the LIKE wildcards so the "contains" filter is always a literal substring
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// buildListPostQuery translates the criteria into a parameterized statement,
+// values are never concatenated into the SQL, only the placeholders
+func buildListPostQuery(query *models.PostQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	addCondition := func(condition string, values ...interface{}) {
+		placeholders := make([]interface{}, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	// deleted posts are only listed in the trash
+	if query.Deleted {
+		addCondition("posts.deleted_at IS NOT NULL")
+	} else {
+		addCondition("posts.deleted_at IS NULL")
+	}
+	addCondition(postVisibleCondition, query.ViewerId)
+	addCondition(authorNotHidden("%[1]s", "posts.user_id"), query.ViewerId)
+	if query.UserId != "" {
+		addCondition("posts.user_id = %s", query.UserId)
+	}
+	if query.Tag != "" {
+		addCondition("EXISTS (SELECT 1 FROM post_tags WHERE post_tags.post_id = posts.id AND post_tags.tag = %s)", query.Tag)
+	}
+	if query.CreatedAfter != nil {
+		addCondition("posts.created_at >= %s", *query.CreatedAfter)
+	}
+	if query.CreatedBefore != nil {
+		addCondition("posts.created_at < %s", *query.CreatedBefore)
+	}
+	if query.Contains != "" {
+		addCondition(`posts.post_content ILIKE '%%' || %s || '%%' ESCAPE '\'`, likeEscaper.Replace(query.Contains))
+	}
+
+	// the cursor moves in the same direction as the sort
+	order := "DESC"
+	comparison := "<"
+	if query.Sort == models.SortOldest {
+		order = "ASC"
+		comparison = ">"
+	}
+	if query.Cursor != nil {
+		addCondition("("+postListedAt+", posts.id) "+comparison+" (%s, %s)", query.Cursor.CreatedAt, query.Cursor.Id)
+	}
+
+	statement := "SELECT " + postColumns + " FROM posts"
+	if len(conditions) > 0 {
+		statement += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, query.Limit)
+	statement += fmt.Sprintf(" ORDER BY %s %s, posts.id %s LIMIT $%d", postListedAt, order, order, len(args))
+
+	return statement, args
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+func TestBuildListPostQuery(t *testing.T) {
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	cursor := &models.Cursor{CreatedAt: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC), Id: "cursor-id"}
+
+	// the conditions that every listing has, the viewer is always $1 and $2
+	visible := func(deleted string) string {
+		return "SELECT " + postColumns + " FROM posts WHERE posts.deleted_at " + deleted + " AND " +
+			fmt.Sprintf(postVisibleCondition, "$1") + " AND " + authorNotHidden("$2", "posts.user_id")
+	}
+	newest := " ORDER BY " + postListedAt + " DESC, posts.id DESC"
+
+	tests := []struct {
+		name          string
+		query         models.PostQuery
+		wantStatement string
+		wantArgs      []interface{}
+	}{
+		{
+			name:          "no filters",
+			query:         models.PostQuery{ViewerId: "viewer", Limit: 20},
+			wantStatement: visible("IS NULL") + newest + " LIMIT $3",
+			wantArgs:      []interface{}{"viewer", "viewer", uint64(20)},
+		},
+		{
+			name:          "trash",
+			query:         models.PostQuery{ViewerId: "viewer", UserId: "viewer", Deleted: true, Limit: 20},
+			wantStatement: visible("IS NOT NULL") + " AND posts.user_id = $3" + newest + " LIMIT $4",
+			wantArgs:      []interface{}{"viewer", "viewer", "viewer", uint64(20)},
+		},
+		{
+			name: "every filter",
+			query: models.PostQuery{
+				ViewerId:      "viewer",
+				UserId:        "author",
+				Tag:           "go",
+				CreatedAfter:  &after,
+				CreatedBefore: &before,
+				Contains:      "fox",
+				Limit:         10,
+			},
+			wantStatement: visible("IS NULL") + " AND posts.user_id = $3" +
+				" AND EXISTS (SELECT 1 FROM post_tags WHERE post_tags.post_id = posts.id AND post_tags.tag = $4)" +
+				" AND posts.created_at >= $5 AND posts.created_at < $6" +
+				` AND posts.post_content ILIKE '%' || $7 || '%' ESCAPE '\'` + newest + " LIMIT $8",
+			wantArgs: []interface{}{"viewer", "viewer", "author", "go", after, before, "fox", uint64(10)},
+		},
+		{
+			name:          "the wildcards of contains are escaped",
+			query:         models.PostQuery{ViewerId: "viewer", Contains: `100%_\`, Limit: 20},
+			wantStatement: visible("IS NULL") + ` AND posts.post_content ILIKE '%' || $3 || '%' ESCAPE '\'` + newest + " LIMIT $4",
+			wantArgs:      []interface{}{"viewer", "viewer", `100\%\_\\`, uint64(20)},
+		},
+		{
+			name:          "cursor of the newest first",
+			query:         models.PostQuery{ViewerId: "viewer", Cursor: cursor, Limit: 20},
+			wantStatement: visible("IS NULL") + " AND (" + postListedAt + ", posts.id) < ($3, $4)" + newest + " LIMIT $5",
+			wantArgs:      []interface{}{"viewer", "viewer", cursor.CreatedAt, "cursor-id", uint64(20)},
+		},
+		{
+			name:  "cursor of the oldest first",
+			query: models.PostQuery{ViewerId: "viewer", Sort: models.SortOldest, Cursor: cursor, Limit: 20},
+			wantStatement: visible("IS NULL") + " AND (" + postListedAt + ", posts.id) > ($3, $4)" +
+				" ORDER BY " + postListedAt + " ASC, posts.id ASC LIMIT $5",
+			wantArgs: []interface{}{"viewer", "viewer", cursor.CreatedAt, "cursor-id", uint64(20)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statement, args := buildListPostQuery(&test.query)
+			if statement != test.wantStatement {
+				t.Errorf("got the statement\n%s\nwant\n%s", statement, test.wantStatement)
+			}
+			if !reflect.DeepEqual(args, test.wantArgs) {
+				t.Errorf("got the args %v, want %v", args, test.wantArgs)
+			}
+		})
+	}
+}
@@ -3,16 +3,65 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/emavillamayorpsh/rest-ws/models"
-	_ "github.com/lib/pq"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/lib/pq"
 )
 
 type PostgresRepository struct {
 	db *sql.DB
 }
 
+// columns of the posts read by every query, in the order expected by scanPost
+const postColumns = `posts.id, posts.post_content, posts.post_html, posts.created_at, posts.updated_at, posts.revision, posts.user_id, posts.deleted_at,
+	posts.status, posts.publish_at, posts.published_at, posts.visibility, posts.repost_of_id, posts.hidden_at`
+
+// the authors see all their posts, the rest of the users only see the published ones allowed by their visibility
+// that were not hidden by the moderation. %[1]s is the placeholder of the viewer
+const postVisibleCondition = `(posts.user_id = %[1]s OR (posts.status = 'published' AND posts.hidden_at IS NULL
+	AND NOT EXISTS (SELECT 1 FROM users suspended WHERE suspended.id = posts.user_id AND suspended.suspended_at IS NOT NULL)
+	AND (posts.visibility = 'public'
+	OR (posts.visibility = 'followers' AND EXISTS (SELECT 1 FROM follows WHERE follows.followee_id = posts.user_id AND follows.follower_id = %[1]s)))))`
+
+// sort key of the listings, it matches models.Post.ListedAt
+const postListedAt = "COALESCE(posts.published_at, posts.created_at)"
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPost reads the postColumns of a row, extra receives the columns selected after them
+func scanPost(row scanner, post *models.Post, extra ...interface{}) error {
+	var deletedAt, publishAt, publishedAt, hiddenAt sql.NullTime
+	var repostOfId sql.NullString
+	dest := []interface{}{&post.Id, &post.PostContent, &post.PostHtml, &post.CreatedAt, &post.UpdatedAt, &post.Revision, &post.UserId, &deletedAt,
+		&post.Status, &publishAt, &publishedAt, &post.Visibility, &repostOfId, &hiddenAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	post.DeletedAt = nullTime(deletedAt)
+	post.PublishAt = nullTime(publishAt)
+	post.PublishedAt = nullTime(publishedAt)
+	post.HiddenAt = nullTime(hiddenAt)
+	if repostOfId.Valid {
+		post.RepostOfId = &repostOfId.String
+	}
+	return nil
+}
+
+func nullTime(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
+
 func NewPostgresRepository(url string) (*PostgresRepository, error) {
 	db, err := sql.Open("postgres", url)
 	if err != nil {
@@ -23,106 +72,269 @@ func NewPostgresRepository(url string) (*PostgresRepository, error) {
 }
 
 func (repo *PostgresRepository) InsertUser(ctx context.Context, user *models.User) error {
-	_, err := repo.db.ExecContext(ctx, "INSERT INTO users (id, email, password) VALUES ($1, $2, $3)", user.Id, user.Email, user.Password)
+	_, err := repo.db.ExecContext(ctx, "INSERT INTO users (id, email, password, username) VALUES ($1, $2, $3, NULLIF($4, ''))", user.Id, user.Email, user.Password, user.Username)
+	if isUniqueViolation(err) {
+		return repository.ErrConflict
+	}
 	return err
 }
 
 func (repo *PostgresRepository) GetUserById(ctx context.Context, id string) (*models.User, error) {
-	rows, err := repo.db.QueryContext(ctx, "SELECT id, email FROM  users WHERE id = $1",id )
-
-	defer func() {
-		err = rows.Close() // close the connection in our db once the function is done
-		if err != nil {
-			log.Fatal(err)
-		}
-	}()
-
 	var user = models.User{}
 
-	// parse the results from the query and convert it into an User model
-	for rows.Next() {
-		if err = rows.Scan(&user.Id, &user.Email); err == nil {
-			return &user, nil
-		}
+	err := scanUser(repo.db.QueryRowContext(ctx, "SELECT "+userProfileColumns+" FROM users WHERE id = $1", id), &user)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
 	}
-
-	// check if there was an error in the query
-	if err = rows.Err(); err != nil {
-		return nil , err
+	if err != nil {
+		return nil, err
 	}
-	return &user , nil
+	return &user, nil
 }
 
 func (repo *PostgresRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
-	rows, err := repo.db.QueryContext(ctx, "SELECT id, email, password FROM  users WHERE email = $1", email )
+	var user = models.User{}
 
-	defer func() {
-		err = rows.Close() // close the connection in our db once the function is done
+	var suspendedAt sql.NullTime
+	err := repo.db.QueryRowContext(ctx, "SELECT id, email, password, COALESCE(username, ''), suspended_at FROM users WHERE email = $1", email).Scan(
+		&user.Id, &user.Email, &user.Password, &user.Username, &suspendedAt)
+	user.SuspendedAt = nullTime(suspendedAt)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// InsertPost stores the post, its first revision, its entities and its attachments in the same transaction. It
+// returns ErrConflict when the user already reposted the same post or one of the media can't be attached
+func (repo *PostgresRepository) InsertPost(ctx context.Context, post *models.Post, entities *models.PostEntities, attachments *models.PostAttachments) error {
+	return repo.withTx(ctx, func(tx *sql.Tx) error {
+		var publishedAt sql.NullTime
+		err := tx.QueryRowContext(ctx, `INSERT INTO posts (id, post_content, post_html, user_id, status, publish_at, published_at, visibility, repost_of_id)
+			VALUES ($1, $2, $3, $4, $5, $6, CASE WHEN $5 = 'published' THEN NOW() END, $7, $8) RETURNING revision, updated_at, published_at`,
+			post.Id, post.PostContent, post.PostHtml, post.UserId, post.Status, post.PublishAt, post.Visibility, post.RepostOfId).Scan(&post.Revision, &post.UpdatedAt, &publishedAt)
+		if isUniqueViolation(err) {
+			return repository.ErrConflict
+		}
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+		post.PublishedAt = nullTime(publishedAt)
+		if err := insertPostRevision(ctx, tx, post); err != nil {
+			return err
+		}
+		if err := setPostEntities(ctx, tx, post.Id, entities); err != nil {
+			return err
 		}
-	}()
 
-	var user = models.User{}
+		if attachments == nil {
+			return nil
+		}
+		if attachments.Poll != nil {
+			attachments.Poll.PostId = post.Id
+			if err := insertPoll(ctx, tx, attachments.Poll); err != nil {
+				return err
+			}
+		}
+		return attachMedia(ctx, tx, post.Id, post.UserId, attachments.MediaIds)
+	})
+}
+
+// GetPostById returns ErrNotFound for the posts that the viewer can't see
+func (repo *PostgresRepository) GetPostById(ctx context.Context, id string, viewerId string) (*models.Post, error) {
+	var post = models.Post{}
+
+	statement := "SELECT " + postColumns + " FROM posts WHERE id = $1 AND deleted_at IS NULL AND " + fmt.Sprintf(postVisibleCondition, "$2")
+	err := scanPost(repo.db.QueryRowContext(ctx, statement, id, viewerId), &post)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// ListPostViewerIds returns the users, out of userIds, that can see the post now and didn't block nor mute its author
+func (repo *PostgresRepository) ListPostViewerIds(ctx context.Context, postId string, userIds []string) ([]string, error) {
+	statement := `SELECT viewers.id FROM posts, UNNEST($2::TEXT[]) AS viewers(id)
+		WHERE posts.id = $1 AND posts.deleted_at IS NULL AND ` + fmt.Sprintf(postVisibleCondition, "viewers.id") +
+		" AND " + authorNotHidden("viewers.id", "posts.user_id")
+	rows, err := repo.db.QueryContext(ctx, statement, postId, pq.Array(userIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	// parse the results from the query and convert it into an User model
+	var viewerIds []string
 	for rows.Next() {
-		if err = rows.Scan(&user.Id, &user.Email, &user.Password); err == nil {
-			return &user, nil
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
 		}
+		viewerIds = append(viewerIds, id)
 	}
+	return viewerIds, rows.Err()
+}
 
-	// check if there was an error in the query
-	if err = rows.Err(); err != nil {
-		return nil , err
+// UpdatePost increments the revision of the post and keeps a copy of the new version with its entities,
+// all in the same transaction so the history never misses a version. The post is filled
+// with the values after the update
+func (repo *PostgresRepository) UpdatePost(ctx context.Context, post *models.Post, entities *models.PostEntities, expectedRevisions []int) error {
+	return repo.withTx(ctx, func(tx *sql.Tx) error {
+		err := scanPost(tx.QueryRowContext(ctx, `UPDATE posts SET post_content = $1, post_html = $2, revision = revision + 1, updated_at = NOW()
+			WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL AND (CARDINALITY($5::INTEGER[]) = 0 OR revision = ANY($5))
+			RETURNING `+postColumns,
+			post.PostContent, post.PostHtml, post.Id, post.UserId, pq.Array(expectedRevisions)), post)
+		if err == sql.ErrNoRows {
+			return repo.postUpdateError(ctx, post.Id, post.UserId)
+		}
+		if err != nil {
+			return err
+		}
+		if err := insertPostRevision(ctx, tx, post); err != nil {
+			return err
+		}
+		return setPostEntities(ctx, tx, post.Id, entities)
+	})
+}
+
+// postUpdateError finds out why an update filtered by owner and revision didn't match the post
+func (repo *PostgresRepository) postUpdateError(ctx context.Context, id string, userId string) error {
+	var ownerId string
+	err := repo.db.QueryRowContext(ctx, "SELECT user_id FROM posts WHERE id = $1 AND deleted_at IS NULL", id).Scan(&ownerId)
+	if err == sql.ErrNoRows {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if ownerId != userId {
+		return repository.ErrForbidden
 	}
-	return &user , nil
+	return repository.ErrPreconditionFailed
 }
 
-func (repo *PostgresRepository) InsertPost(ctx context.Context, post *models.Post) error {
-	_, err := repo.db.ExecContext(ctx, "INSERT INTO posts (id, post_content, user_id) VALUES ($1, $2, $3)", post.Id, post.PostContent, post.UserId)
-	return err
+// DeletePost moves the post to the trash of its author, it is removed for good by PurgeDeletedPosts
+func (repo *PostgresRepository) DeletePost(ctx context.Context, id string, userId string) error {
+	result, err := repo.db.ExecContext(ctx, "UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL", id, userId)
+	if err != nil {
+		return err
+	}
+	return repo.checkPostOwnership(ctx, result, id)
 }
 
-func (repo *PostgresRepository) GetPostById(ctx context.Context, id string) (*models.Post, error) {
-	rows, err := repo.db.QueryContext(ctx, "SELECT id, post_content, created_at, user_id FROM  posts WHERE id = $1",id )
+// RestorePost takes the post out of the trash
+func (repo *PostgresRepository) RestorePost(ctx context.Context, id string, userId string) error {
+	result, err := repo.db.ExecContext(ctx, "UPDATE posts SET deleted_at = NULL WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL", id, userId)
+	// the same post was reposted again while this repost was in the trash
+	if isUniqueViolation(err) {
+		return repository.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
 
-	defer func() {
-		err = rows.Close() // close the connection in our db once the function is done
-		if err != nil {
-			log.Fatal(err)
-		}
-	}()
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
 
-	var post = models.Post{}
+	var owned bool
+	err = repo.db.QueryRowContext(ctx, "SELECT user_id = $2 FROM posts WHERE id = $1 AND deleted_at IS NOT NULL", id, userId).Scan(&owned)
+	if err == sql.ErrNoRows || (err == nil && owned) {
+		return repository.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return repository.ErrForbidden
+}
 
-	// parse the results from the query and convert it into a Post model
-	for rows.Next() {
-		if err = rows.Scan(&post.Id, &post.PostContent, &post.CreatedAt, &post.UserId); err == nil {
-			return &post, nil
-		}
+// PurgeDeletedPosts removes for good the posts deleted before the date, with everything that references them.
+// It returns the number of posts removed and the storage keys of their media and variants, the caller
+// deletes the objects. The keys are read in the same statement, before the media rows are cascaded
+func (repo *PostgresRepository) PurgeDeletedPosts(ctx context.Context, deletedBefore time.Time) (int64, []string, error) {
+	var purged int64
+	var storageKeys []string
+	err := repo.db.QueryRowContext(ctx, `WITH purged AS (DELETE FROM posts WHERE deleted_at < $1 RETURNING id)
+		SELECT (SELECT COUNT(*) FROM purged), ARRAY(
+			SELECT media.storage_key FROM media JOIN purged ON purged.id = media.post_id
+			UNION ALL
+			SELECT media_variants.storage_key FROM media_variants
+				JOIN media ON media.id = media_variants.media_id JOIN purged ON purged.id = media.post_id)`,
+		deletedBefore).Scan(&purged, pq.Array(&storageKeys))
+	if err != nil {
+		return 0, nil, err
 	}
+	return purged, storageKeys, nil
+}
 
-	// check if there was an error in the query
-	if err = rows.Err(); err != nil {
-		return nil , err
+// checkPostOwnership is called after a statement filtered by id and user_id, when no rows
+// were affected it finds out if the post doesn't exist or if it belongs to another user
+func (repo *PostgresRepository) checkPostOwnership(ctx context.Context, result sql.Result, id string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
 	}
-	return &post , nil
+	if affected > 0 {
+		return nil
+	}
+	return repo.postOwnershipError(ctx, id)
 }
 
-func (repo *PostgresRepository) UpdatePost(ctx context.Context, post *models.Post) error {
-	_, err := repo.db.ExecContext(ctx, "UPDATE posts SET post_content = $1 WHERE id = $2 AND user_id = $3", post.PostContent, post.Id, post.UserId)
-	return err
+func (repo *PostgresRepository) postOwnershipError(ctx context.Context, id string) error {
+	var exists bool
+	if err := repo.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return repository.ErrForbidden
+	}
+	return repository.ErrNotFound
 }
 
-func (repo *PostgresRepository) DeletePost(ctx context.Context, id string, userId string) error {
-	_, err := repo.db.ExecContext(ctx, "DELETE from posts WHERE id = $1 AND user_id = $2", id, userId)
-	return err
+// withTx runs fn inside of a transaction, it is committed only when fn doesn't return an error
+func (repo *PostgresRepository) withTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
+// isUniqueViolation checks the postgres error code of the unique constraints
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
 
-func (repo *PostgresRepository) ListPost(ctx context.Context, page uint64) ([]*models.Post, error) {
-	rows, err := repo.db.QueryContext(ctx, "SELECT id, post_content, created_at, user_id FROM posts LIMIT $1 OFFSET $2", 2, page * 2)
+// isForeignKeyViolation checks the postgres error code of the foreign keys, the referenced row doesn't exist
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+
+func (repo *PostgresRepository) ListPost(ctx context.Context, query *models.PostQuery) ([]*models.Post, error) {
+	statement, args := buildListPostQuery(query)
+	return repo.queryPosts(ctx, statement, args...)
+}
+
+// queryPosts runs a statement that selects the postColumns
+func (repo *PostgresRepository) queryPosts(ctx context.Context, statement string, args ...interface{}) ([]*models.Post, error) {
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
 	if err != nil {
 		return nil ,err
 	}
@@ -138,7 +350,7 @@ func (repo *PostgresRepository) ListPost(ctx context.Context, page uint64) ([]*m
 	// start loading the array of posts with the data get from the DB
 	for rows.Next() {
 		var post = models.Post{}
-		if err = rows.Scan(&post.Id, &post.PostContent, &post.CreatedAt, &post.UserId); err == nil {
+		if err = scanPost(rows, &post); err == nil {
 			posts = append(posts, &post)
 		}
 	}
@@ -154,3 +366,16 @@ func (repo *PostgresRepository) ListPost(ctx context.Context, page uint64) ([]*m
 func (repo *PostgresRepository) Close() error {
 	return repo.db.Close()
 }
+
+// PublishDuePosts publishes the scheduled posts whose time has come and returns them.
+// The rows are locked with SKIP LOCKED so when many replicas run it at the same time
+// every post is published (and notified) by only one of them. The posts of the suspended users are not published
+func (repo *PostgresRepository) PublishDuePosts(ctx context.Context, limit int) ([]*models.Post, error) {
+	return repo.queryPosts(ctx, `UPDATE posts SET status = 'published', published_at = NOW()
+		WHERE id IN (
+			SELECT id FROM posts WHERE status = 'scheduled' AND publish_at <= NOW() AND deleted_at IS NULL
+				AND NOT EXISTS (SELECT 1 FROM users suspended WHERE suspended.id = posts.user_id AND suspended.suspended_at IS NOT NULL)
+			ORDER BY publish_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		) AND status = 'scheduled'
+		RETURNING `+postColumns, limit)
+}
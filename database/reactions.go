@@ -0,0 +1,80 @@
+package database
+
+import (
+	"context"
+	"log"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/lib/pq"
+)
+
+// AddReaction is idempotent, reacting twice with the same kind keeps a single reaction.
+// The counter is only incremented when the reaction was inserted, both in the same statement
+func (repo *PostgresRepository) AddReaction(ctx context.Context, postId string, userId string, kind string) error {
+	_, err := repo.db.ExecContext(ctx, `WITH inserted AS (
+			INSERT INTO reactions (post_id, user_id, kind) VALUES ($1, $2, $3)
+			ON CONFLICT (post_id, user_id, kind) DO NOTHING
+			RETURNING post_id, kind
+		)
+		INSERT INTO reaction_counts (post_id, kind, count) SELECT post_id, kind, 1 FROM inserted
+		ON CONFLICT (post_id, kind) DO UPDATE SET count = reaction_counts.count + 1`, postId, userId, kind)
+	return err
+}
+
+// RemoveReaction is idempotent too, the counter is only decremented when a reaction was deleted
+func (repo *PostgresRepository) RemoveReaction(ctx context.Context, postId string, userId string, kind string) error {
+	_, err := repo.db.ExecContext(ctx, `WITH deleted AS (
+			DELETE FROM reactions WHERE post_id = $1 AND user_id = $2 AND kind = $3
+			RETURNING post_id, kind
+		)
+		UPDATE reaction_counts SET count = reaction_counts.count - 1 FROM deleted
+		WHERE reaction_counts.post_id = deleted.post_id AND reaction_counts.kind = deleted.kind`, postId, userId, kind)
+	return err
+}
+
+// GetReactionSummaries reads the counters of all the posts in a single query,
+// the posts without reactions are not in the map
+func (repo *PostgresRepository) GetReactionSummaries(ctx context.Context, postIds []string, viewerId string) (map[string]*models.ReactionSummary, error) {
+	summaries := map[string]*models.ReactionSummary{}
+	if len(postIds) == 0 {
+		return summaries, nil
+	}
+
+	rows, err := repo.db.QueryContext(ctx, `SELECT counts.post_id, counts.kind, counts.count,
+			EXISTS (SELECT 1 FROM reactions WHERE reactions.post_id = counts.post_id AND reactions.kind = counts.kind AND reactions.user_id = $2)
+		FROM reaction_counts counts WHERE counts.post_id = ANY($1) AND counts.count > 0`, pq.Array(postIds), viewerId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	for rows.Next() {
+		var postId, kind string
+		var count int
+		var mine bool
+		if err = rows.Scan(&postId, &kind, &count, &mine); err != nil {
+			continue
+		}
+
+		summary, ok := summaries[postId]
+		if !ok {
+			summary = &models.ReactionSummary{Counts: map[string]int{}, Mine: []string{}}
+			summaries[postId] = summary
+		}
+		summary.Counts[kind] = count
+		if mine {
+			summary.Mine = append(summary.Mine, kind)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
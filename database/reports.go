@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/lib/pq"
+	"github.com/segmentio/ksuid"
+)
+
+// InsertReport stores the report and hides the post when it reaches autoHideThreshold open reports, the automatic
+// hide is recorded as a moderation action without moderator. It returns ErrConflict when the user already
+// reported the post
+func (repo *PostgresRepository) InsertReport(ctx context.Context, report *models.Report, autoHideThreshold int) error {
+	return repo.withTx(ctx, func(tx *sql.Tx) error {
+		// the concurrent reports of the post wait for this one, otherwise each of them could count one
+		// report less than the threshold and the post would never be hidden
+		var postId string
+		err := tx.QueryRowContext(ctx, "SELECT id FROM posts WHERE id = $1 FOR UPDATE", report.PostId).Scan(&postId)
+		if err == sql.ErrNoRows {
+			return repository.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, `INSERT INTO reports (id, post_id, reporter_id, reason, details) VALUES ($1, $2, $3, $4, $5)
+			RETURNING created_at`, report.Id, report.PostId, report.ReporterId, report.Reason, report.Details).Scan(&report.CreatedAt)
+		if isUniqueViolation(err) {
+			return repository.ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		var authorId string
+		err = tx.QueryRowContext(ctx, `UPDATE posts SET hidden_at = NOW()
+			WHERE id = $1 AND hidden_at IS NULL AND (SELECT COUNT(*) FROM reports WHERE post_id = $1 AND resolved_at IS NULL) >= $2
+			RETURNING user_id`, report.PostId, autoHideThreshold).Scan(&authorId)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return insertModerationAction(ctx, tx, &models.ModerationAction{
+			Id:       ksuid.New().String(),
+			PostId:   report.PostId,
+			AuthorId: authorId,
+			Action:   models.ActionAutoHide,
+			Note:     fmt.Sprintf("%d open reports", autoHideThreshold),
+		})
+	})
+}
+
+func insertModerationAction(ctx context.Context, tx *sql.Tx, action *models.ModerationAction) error {
+	return tx.QueryRowContext(ctx, `INSERT INTO moderation_actions (id, post_id, author_id, moderator_id, action, note)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at`,
+		action.Id, action.PostId, action.AuthorId, action.ModeratorId, action.Action, action.Note).Scan(&action.CreatedAt)
+}
+
+// ListReportedPosts is the moderation queue: the posts with open reports, the ones reported last first.
+// The moderators see the posts whatever their visibility, even the hidden or deleted ones
+func (repo *PostgresRepository) ListReportedPosts(ctx context.Context, query *models.ModerationQuery) ([]*models.ReportedPost, error) {
+	var args []interface{}
+	having := ""
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.CreatedAt, query.Cursor.Id)
+		having = "HAVING (MAX(reports.created_at), posts.id) < ($1, $2)"
+	}
+	args = append(args, query.Limit)
+
+	rows, err := repo.db.QueryContext(ctx, fmt.Sprintf(`SELECT posts.id, posts.user_id, posts.post_content, posts.post_html, posts.hidden_at,
+			COUNT(*), ARRAY_AGG(reports.reason), MIN(reports.created_at), MAX(reports.created_at)
+		FROM reports JOIN posts ON posts.id = reports.post_id
+		WHERE reports.resolved_at IS NULL
+		GROUP BY posts.id %s
+		ORDER BY MAX(reports.created_at) DESC, posts.id DESC LIMIT $%d`, having, len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []*models.ReportedPost
+	for rows.Next() {
+		var post = models.ReportedPost{Reasons: map[string]int{}}
+		var hiddenAt sql.NullTime
+		var reasons []string
+		if err := rows.Scan(&post.PostId, &post.AuthorId, &post.PostContent, &post.PostHtml, &hiddenAt,
+			&post.Reports, pq.Array(&reasons), &post.FirstReportedAt, &post.LastReportedAt); err != nil {
+			return nil, err
+		}
+		post.HiddenAt = nullTime(hiddenAt)
+		for _, reason := range reasons {
+			post.Reasons[reason]++
+		}
+		posts = append(posts, &post)
+	}
+	return posts, rows.Err()
+}
+
+// ModeratePost applies the action to the post, or to its author, and resolves the open reports of the post
+// with it. action.AuthorId and action.CreatedAt are filled. It returns ErrNotFound when the post doesn't exist
+func (repo *PostgresRepository) ModeratePost(ctx context.Context, action *models.ModerationAction) error {
+	return repo.withTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, "SELECT user_id FROM posts WHERE id = $1 FOR UPDATE", action.PostId).Scan(&action.AuthorId)
+		if err == sql.ErrNoRows {
+			return repository.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		switch action.Action {
+		case models.ActionDismiss:
+			_, err = tx.ExecContext(ctx, "UPDATE posts SET hidden_at = NULL WHERE id = $1", action.PostId)
+		case models.ActionHide:
+			_, err = tx.ExecContext(ctx, "UPDATE posts SET hidden_at = COALESCE(hidden_at, NOW()) WHERE id = $1", action.PostId)
+		case models.ActionSuspend:
+			_, err = tx.ExecContext(ctx, "UPDATE users SET suspended_at = COALESCE(suspended_at, NOW()) WHERE id = $1", action.AuthorId)
+		default:
+			return fmt.Errorf("unknown moderation action %q", action.Action)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE reports SET resolved_at = NOW(), resolved_by = $2, resolution = $3
+			WHERE post_id = $1 AND resolved_at IS NULL`, action.PostId, action.ModeratorId, action.Action)
+		if err != nil {
+			return err
+		}
+
+		return insertModerationAction(ctx, tx, action)
+	})
+}
+
+// ListModerationActions returns the record of the decisions, the newest first
+func (repo *PostgresRepository) ListModerationActions(ctx context.Context, query *models.ModerationQuery) ([]*models.ModerationAction, error) {
+	var args []interface{}
+	statement := "SELECT id, post_id, author_id, moderator_id, action, note, created_at FROM moderation_actions"
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.CreatedAt, query.Cursor.Id)
+		statement += " WHERE (created_at, id) < ($1, $2)"
+	}
+	args = append(args, query.Limit)
+	statement += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []*models.ModerationAction
+	for rows.Next() {
+		var action = models.ModerationAction{}
+		var moderatorId sql.NullString
+		if err := rows.Scan(&action.Id, &action.PostId, &action.AuthorId, &moderatorId, &action.Action, &action.Note, &action.CreatedAt); err != nil {
+			return nil, err
+		}
+		if moderatorId.Valid {
+			action.ModeratorId = &moderatorId.String
+		}
+		actions = append(actions, &action)
+	}
+	return actions, rows.Err()
+}
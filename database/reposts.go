@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/lib/pq"
+)
+
+// CountReposts returns the reposts and quotes that are published of every post, the posts
+// that were never reposted are not in the map
+func (repo *PostgresRepository) CountReposts(ctx context.Context, postIds []string) (map[string]*models.RepostCounts, error) {
+	counts := map[string]*models.RepostCounts{}
+	if len(postIds) == 0 {
+		return counts, nil
+	}
+
+	rows, err := repo.db.QueryContext(ctx, `SELECT repost_of_id, COUNT(*) FILTER (WHERE post_content = ''), COUNT(*) FILTER (WHERE post_content <> '')
+		FROM posts WHERE repost_of_id = ANY($1) AND deleted_at IS NULL AND status = 'published' GROUP BY repost_of_id`, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postId string
+		var count = models.RepostCounts{}
+		if err := rows.Scan(&postId, &count.Reposts, &count.Quotes); err != nil {
+			return nil, err
+		}
+		counts[postId] = &count
+	}
+	return counts, rows.Err()
+}
+
+// DeleteRepost undoes the repost of the post done by the user, the repost is removed for good
+// because it has no content to restore
+func (repo *PostgresRepository) DeleteRepost(ctx context.Context, postId string, userId string) error {
+	result, err := repo.db.ExecContext(ctx, "DELETE FROM posts WHERE repost_of_id = $1 AND user_id = $2 AND post_content = '' AND deleted_at IS NULL", postId, userId)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
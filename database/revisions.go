@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+)
+
+func insertPostRevision(ctx context.Context, tx *sql.Tx, post *models.Post) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO post_revisions (post_id, revision, post_content, post_html, created_at) VALUES ($1, $2, $3, $4, $5)",
+		post.Id, post.Revision, post.PostContent, post.PostHtml, post.UpdatedAt)
+	return err
+}
+
+func (repo *PostgresRepository) GetPostRevision(ctx context.Context, postId string, revision int) (*models.PostRevision, error) {
+	var postRevision = models.PostRevision{}
+
+	err := repo.db.QueryRowContext(ctx, "SELECT post_id, revision, post_content, post_html, created_at FROM post_revisions WHERE post_id = $1 AND revision = $2", postId, revision).
+		Scan(&postRevision.PostId, &postRevision.Revision, &postRevision.PostContent, &postRevision.PostHtml, &postRevision.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &postRevision, nil
+}
+
+// ListPostRevisions returns the newest revisions first, only the ones older than before when it is not 0
+func (repo *PostgresRepository) ListPostRevisions(ctx context.Context, postId string, before int, limit uint64) ([]*models.PostRevision, error) {
+	rows, err := repo.db.QueryContext(ctx, `SELECT post_id, revision, post_content, post_html, created_at FROM post_revisions
+		WHERE post_id = $1 AND ($2 = 0 OR revision < $2) ORDER BY revision DESC LIMIT $3`, postId, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	var revisions []*models.PostRevision
+
+	for rows.Next() {
+		var postRevision = models.PostRevision{}
+		if err = rows.Scan(&postRevision.PostId, &postRevision.Revision, &postRevision.PostContent, &postRevision.PostHtml, &postRevision.CreatedAt); err == nil {
+			revisions = append(revisions, &postRevision)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/search"
+)
+
+const (
+	TEXT_SEARCH_CONFIG = "english"
+)
+
+var headlineOptions = fmt.Sprintf(`StartSel="%s", StopSel="%s", MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=" ... "`, search.StartMark, search.StopMark)
+
+// toTsQuery converts the parsed search into the to_tsquery syntax, the words only contain letters and digits
+func toTsQuery(query search.Query) string {
+	terms := make([]string, len(query.Terms))
+	for i, term := range query.Terms {
+		terms[i] = strings.Join(term.Words, " <-> ")
+		if term.Prefix {
+			terms[i] += ":*"
+		}
+		if len(term.Words) > 1 {
+			terms[i] = "(" + terms[i] + ")"
+		}
+	}
+	return strings.Join(terms, " & ")
+}
+
+func (repo *PostgresRepository) SearchPosts(ctx context.Context, query *models.PostSearchQuery) ([]*models.PostSearchResult, error) {
+	parsed := search.Parse(query.Query)
+	if parsed.IsEmpty() {
+		return nil, nil
+	}
+
+	args := []interface{}{TEXT_SEARCH_CONFIG, toTsQuery(parsed), headlineOptions, query.ViewerId}
+	statement := `SELECT ` + postColumns + `, matches.rank, ts_headline($1::regconfig, posts.post_content, matches.query, $3)
+		FROM posts
+		JOIN (
+			SELECT id, ts_rank(search_vector, query) AS rank, query
+			FROM posts, to_tsquery($1::regconfig, $2) query
+			WHERE search_vector @@ query AND deleted_at IS NULL
+		) matches ON matches.id = posts.id
+		WHERE ` + fmt.Sprintf(postVisibleCondition, "$4") + " AND " + authorNotHidden("$4", "posts.user_id")
+
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.Rank, query.Cursor.Id)
+		statement += " AND (matches.rank, posts.id) < ($5, $6)"
+	}
+
+	args = append(args, query.Limit)
+	statement += fmt.Sprintf(" ORDER BY matches.rank DESC, posts.id DESC LIMIT $%d", len(args))
+
+	rows, err := repo.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	var results []*models.PostSearchResult
+
+	for rows.Next() {
+		var result = models.PostSearchResult{}
+		if err = scanPost(rows, &result.Post, &result.Rank, &result.Snippet); err == nil {
+			result.Snippet = search.Highlight(result.Snippet)
+			results = append(results, &result)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/lib/pq"
+)
+
+// setPostEntities reconciles the tags and the mentioned users of the post with the ones received, inside of
+// the transaction that stores its content. The users that were not mentioned before and can see the post are
+// kept in entities.AddedMentionIds, so only them are notified. The users that blocked the author are never mentioned
+func setPostEntities(ctx context.Context, tx *sql.Tx, postId string, entities *models.PostEntities) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM post_tags WHERE post_id = $1 AND NOT (tag = ANY($2))", postId, pq.Array(entities.Tags)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO post_tags (post_id, tag) SELECT $1, UNNEST($2::VARCHAR[]) ON CONFLICT DO NOTHING", postId, pq.Array(entities.Tags)); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM post_mentions WHERE post_id = $1 AND NOT (user_id = ANY($2))", postId, pq.Array(entities.MentionIds)); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `WITH added AS (
+			INSERT INTO post_mentions (post_id, user_id)
+			SELECT posts.id, mentioned.user_id FROM posts, UNNEST($2::VARCHAR[]) AS mentioned(user_id)
+			WHERE posts.id = $1 AND NOT EXISTS (SELECT 1 FROM blocks WHERE blocks.blocker_id = mentioned.user_id AND blocks.blocked_id = posts.user_id)
+			ON CONFLICT DO NOTHING RETURNING user_id
+		)
+		SELECT added.user_id FROM added JOIN posts ON posts.id = $1 WHERE `+fmt.Sprintf(postVisibleCondition, "added.user_id"), postId, pq.Array(entities.MentionIds))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	entities.AddedMentionIds = nil
+	for rows.Next() {
+		var userId string
+		if err := rows.Scan(&userId); err != nil {
+			return err
+		}
+		entities.AddedMentionIds = append(entities.AddedMentionIds, userId)
+	}
+	return rows.Err()
+}
+
+// GetUserIdsByUsernames maps every username that exists to the id of its user
+func (repo *PostgresRepository) GetUserIdsByUsernames(ctx context.Context, usernames []string) (map[string]string, error) {
+	ids := map[string]string{}
+	if len(usernames) == 0 {
+		return ids, nil
+	}
+
+	rows, err := repo.db.QueryContext(ctx, "SELECT LOWER(username), id FROM users WHERE LOWER(username) = ANY($1)", pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	for rows.Next() {
+		var username, id string
+		if err = rows.Scan(&username, &id); err == nil {
+			ids[username] = id
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// ListPostMentions returns the mentioned users that can see the post
+func (repo *PostgresRepository) ListPostMentions(ctx context.Context, postId string) ([]string, error) {
+	rows, err := repo.db.QueryContext(ctx, `SELECT post_mentions.user_id FROM post_mentions JOIN posts ON posts.id = post_mentions.post_id
+		WHERE post_mentions.post_id = $1 AND `+fmt.Sprintf(postVisibleCondition, "post_mentions.user_id"), postId)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = rows.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	var userIds []string
+
+	for rows.Next() {
+		var userId string
+		if err = rows.Scan(&userId); err == nil {
+			userIds = append(userIds, userId)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return userIds, nil
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+// ListTimeline builds the timeline when it is read (fan-out-on-read): the posts of the followed
+// users are selected every time, so there is nothing to store when a post is published
+func (repo *PostgresRepository) ListTimeline(ctx context.Context, query *models.TimelineQuery) ([]*models.Post, error) {
+	args := []interface{}{query.UserId}
+	statement := "SELECT " + postColumns + ` FROM posts
+		WHERE posts.deleted_at IS NULL AND posts.status = 'published'
+		AND (posts.user_id = $1 OR posts.user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1))
+		AND ` + fmt.Sprintf(postVisibleCondition, "$1") + " AND " + authorNotHidden("$1", "posts.user_id")
+
+	if query.Cursor != nil {
+		args = append(args, query.Cursor.CreatedAt, query.Cursor.Id)
+		statement += fmt.Sprintf(" AND (%s, posts.id) < ($%d, $%d)", postListedAt, len(args)-1, len(args))
+	}
+
+	args = append(args, query.Limit)
+	statement += fmt.Sprintf(" ORDER BY %s DESC, posts.id DESC LIMIT $%d", postListedAt, len(args))
+
+	return repo.queryPosts(ctx, statement, args...)
+}
+
+// FanOutPost does nothing, the timelines are built when they are read
+func (repo *PostgresRepository) FanOutPost(ctx context.Context, post *models.Post) error {
+	return nil
+}
@@ -1,18 +1,312 @@
+
+DROP TABLE IF EXISTS mutes;
+DROP TABLE IF EXISTS blocks;
+DROP TABLE IF EXISTS follows;
 DROP TABLE if EXISTS users;
 
 CREATE TABLE users (
   id VARCHAR(32) PRIMARY KEY,
   password VARCHAR(255) NOT NULL,
-  email VARCHAR(255) NOT NULL,
+  email VARCHAR(255) NOT NULL UNIQUE,
+  username VARCHAR(30),
+  display_name VARCHAR(50) NOT NULL DEFAULT '',
+  bio VARCHAR(160) NOT NULL DEFAULT '',
+  avatar_url VARCHAR(2048) NOT NULL DEFAULT '',
+  -- user or moderator, the moderators are promoted directly in the database
+  role VARCHAR(16) NOT NULL DEFAULT 'user',
+  -- the suspended users can't log in nor change anything, and their posts are hidden
+  suspended_at TIMESTAMP,
   created_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
+-- usernames are unique without taking the case into account
+CREATE UNIQUE INDEX users_username_idx ON users (LOWER(username));
+
+CREATE TABLE follows(
+  follower_id VARCHAR(32) NOT NULL,
+  followee_id VARCHAR(32) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (follower_id, followee_id),
+  FOREIGN KEY (follower_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (followee_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- used by the followers lists, the PRIMARY KEY is used to find the followed users
+CREATE INDEX follows_followee_id_idx ON follows (followee_id, created_at DESC);
+
+-- used by the following lists
+CREATE INDEX follows_follower_id_idx ON follows (follower_id, created_at DESC);
+
+-- the blocked users can't follow, mention, comment or react to the posts of the blocker
+CREATE TABLE blocks(
+  blocker_id VARCHAR(32) NOT NULL,
+  blocked_id VARCHAR(32) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (blocker_id, blocked_id),
+  FOREIGN KEY (blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX blocks_blocked_id_idx ON blocks (blocked_id);
+
+-- the posts and comments of the muted users are hidden to the muter, nothing else changes
+CREATE TABLE mutes(
+  muter_id VARCHAR(32) NOT NULL,
+  muted_id VARCHAR(32) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (muter_id, muted_id),
+  FOREIGN KEY (muter_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (muted_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX mutes_muted_id_idx ON mutes (muted_id);
+
+DROP TABLE IF EXISTS bookmarks;
+DROP TABLE IF EXISTS bookmark_collections;
+DROP TABLE IF EXISTS post_revisions;
+DROP TABLE IF EXISTS post_mentions;
+DROP TABLE IF EXISTS post_tags;
+DROP TABLE IF EXISTS reaction_counts;
+DROP TABLE IF EXISTS reactions;
+DROP TABLE IF EXISTS comments;
 DROP TABLE IF EXISTS posts;
 
 CREATE TABLE posts(
   id VARCHAR(32) PRIMARY KEY,
-  post_content VARCHAR(32) NOT NULL,
+  post_content TEXT NOT NULL,
+  post_html TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  revision INTEGER NOT NULL DEFAULT 1,
+  user_id VARCHAR(32) NOT NULL,
+  deleted_at TIMESTAMP,
+  status VARCHAR(16) NOT NULL DEFAULT 'published',
+  publish_at TIMESTAMP,
+  published_at TIMESTAMP,
+  visibility VARCHAR(16) NOT NULL DEFAULT 'public',
+  -- without a foreign key so the reposts and quotes keep the id when the original is purged
+  repost_of_id VARCHAR(32),
+  -- set by the moderators or when the post gets too many reports, only its author sees it
+  hidden_at TIMESTAMP,
+  search_vector TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', post_content)) STORED,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+-- used by the keyset pagination of the posts listing, sorted like models.Post.ListedAt
+CREATE INDEX posts_listed_at_id_idx ON posts ((COALESCE(published_at, created_at)) DESC, id DESC);
+
+-- used when the posts listing is filtered by author
+CREATE INDEX posts_user_id_idx ON posts (user_id, created_at DESC);
+
+-- used by the scheduler to find the posts that must be published
+CREATE INDEX posts_publish_at_idx ON posts (publish_at) WHERE status = 'scheduled';
+
+-- used by the trash and by the purge of the deleted posts
+CREATE INDEX posts_deleted_at_idx ON posts (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- used to count and undo the reposts, an user can only repost a post once
+CREATE INDEX posts_repost_of_id_idx ON posts (repost_of_id) WHERE repost_of_id IS NOT NULL;
+CREATE UNIQUE INDEX posts_reposts_idx ON posts (user_id, repost_of_id)
+  WHERE repost_of_id IS NOT NULL AND post_content = '' AND deleted_at IS NULL;
+
+-- used by the full text search of posts
+CREATE INDEX posts_search_vector_idx ON posts USING GIN (search_vector);
+
+CREATE TABLE comments(
+  id VARCHAR(32) PRIMARY KEY,
+  post_id VARCHAR(32) NOT NULL,
+  parent_id VARCHAR(32),
+  user_id VARCHAR(32) NOT NULL,
+  comment_content TEXT NOT NULL,
+  comment_html TEXT NOT NULL DEFAULT '',
   created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX comments_post_id_idx ON comments (post_id, created_at, id);
+CREATE INDEX comments_parent_id_idx ON comments (parent_id, created_at, id);
+
+CREATE TABLE reactions(
+  post_id VARCHAR(32) NOT NULL,
   user_id VARCHAR(32) NOT NULL,
+  kind VARCHAR(16) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (post_id, user_id, kind),
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
   FOREIGN KEY (user_id) REFERENCES users(id)
 );
+
+-- kept up to date in the same statements that insert or delete the reactions
+CREATE TABLE reaction_counts(
+  post_id VARCHAR(32) NOT NULL,
+  kind VARCHAR(16) NOT NULL,
+  count INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (post_id, kind),
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE post_tags(
+  post_id VARCHAR(32) NOT NULL,
+  tag VARCHAR(64) NOT NULL,
+  PRIMARY KEY (post_id, tag),
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX post_tags_tag_idx ON post_tags (tag);
+
+CREATE TABLE post_mentions(
+  post_id VARCHAR(32) NOT NULL,
+  user_id VARCHAR(32) NOT NULL,
+  PRIMARY KEY (post_id, user_id),
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE post_revisions(
+  post_id VARCHAR(32) NOT NULL,
+  revision INTEGER NOT NULL,
+  post_content TEXT NOT NULL,
+  post_html TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (post_id, revision),
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE TABLE bookmark_collections(
+  id VARCHAR(32) PRIMARY KEY,
+  user_id VARCHAR(32) NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- the names of the collections of an user are unique without taking the case into account
+CREATE UNIQUE INDEX bookmark_collections_name_idx ON bookmark_collections (user_id, LOWER(name));
+CREATE INDEX bookmark_collections_user_id_idx ON bookmark_collections (user_id, created_at DESC, id DESC);
+
+-- the bookmarks are kept while the post is in the trash, they are removed when it is purged
+CREATE TABLE bookmarks(
+  collection_id VARCHAR(32) NOT NULL,
+  post_id VARCHAR(32) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (collection_id, post_id),
+  FOREIGN KEY (collection_id) REFERENCES bookmark_collections(id) ON DELETE CASCADE,
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX bookmarks_collection_id_idx ON bookmarks (collection_id, created_at DESC, post_id DESC);
+
+-- the poll of a post, closed_at is set by the scheduler once closes_at is due
+CREATE TABLE polls(
+  post_id VARCHAR(32) PRIMARY KEY,
+  multiple BOOLEAN NOT NULL DEFAULT FALSE,
+  closes_at TIMESTAMP NULL,
+  closed_at TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX polls_closes_at_idx ON polls (closes_at) WHERE closed_at IS NULL AND closes_at IS NOT NULL;
+
+CREATE TABLE poll_options(
+  id VARCHAR(32) PRIMARY KEY,
+  post_id VARCHAR(32) NOT NULL,
+  position SMALLINT NOT NULL,
+  option_text VARCHAR(100) NOT NULL,
+  UNIQUE (post_id, position),
+  -- referenced by the votes so an user can only vote the options of the poll
+  UNIQUE (post_id, id),
+  FOREIGN KEY (post_id) REFERENCES polls(post_id) ON DELETE CASCADE
+);
+
+-- a single ballot per user and poll, with multiple choice it has several votes
+CREATE TABLE poll_ballots(
+  post_id VARCHAR(32) NOT NULL,
+  user_id VARCHAR(32) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (post_id, user_id),
+  FOREIGN KEY (post_id) REFERENCES polls(post_id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE poll_votes(
+  post_id VARCHAR(32) NOT NULL,
+  user_id VARCHAR(32) NOT NULL,
+  option_id VARCHAR(32) NOT NULL,
+  PRIMARY KEY (post_id, user_id, option_id),
+  FOREIGN KEY (post_id, user_id) REFERENCES poll_ballots(post_id, user_id) ON DELETE CASCADE,
+  FOREIGN KEY (post_id, option_id) REFERENCES poll_options(post_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX poll_votes_option_id_idx ON poll_votes (option_id);
+
+-- the uploaded files, their content is in the storage. position keeps the order of the media of a post,
+-- status is the one of the generation of the variants of the images
+CREATE TABLE media(
+  id VARCHAR(32) PRIMARY KEY,
+  user_id VARCHAR(32) NOT NULL,
+  post_id VARCHAR(32) NULL,
+  position SMALLINT NULL,
+  storage_key VARCHAR(255) NOT NULL UNIQUE,
+  content_type VARCHAR(100) NOT NULL,
+  file_name VARCHAR(255) NOT NULL DEFAULT '',
+  size BIGINT NOT NULL,
+  status VARCHAR(16) NOT NULL DEFAULT 'ready',
+  processing_started_at TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX media_post_id_idx ON media (post_id, position) WHERE post_id IS NOT NULL;
+CREATE INDEX media_unattached_idx ON media (created_at) WHERE post_id IS NULL;
+CREATE INDEX media_pending_idx ON media (created_at) WHERE status IN ('pending', 'processing') AND post_id IS NOT NULL;
+
+CREATE TABLE media_variants(
+  media_id VARCHAR(32) NOT NULL,
+  name VARCHAR(16) NOT NULL,
+  storage_key VARCHAR(255) NOT NULL UNIQUE,
+  content_type VARCHAR(100) NOT NULL,
+  width INTEGER NOT NULL,
+  height INTEGER NOT NULL,
+  size BIGINT NOT NULL,
+  PRIMARY KEY (media_id, name),
+  FOREIGN KEY (media_id) REFERENCES media(id) ON DELETE CASCADE
+);
+
+-- an user can only report a post once, the reports are open until a moderator resolves them.
+-- post_id has no foreign key, like repost_of_id, so the reports outlive the posts purged from the trash
+CREATE TABLE reports(
+  id VARCHAR(32) PRIMARY KEY,
+  post_id VARCHAR(32) NOT NULL,
+  reporter_id VARCHAR(32) NOT NULL,
+  reason VARCHAR(32) NOT NULL,
+  details VARCHAR(500) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  resolved_at TIMESTAMP,
+  resolved_by VARCHAR(32),
+  resolution VARCHAR(16),
+  UNIQUE (post_id, reporter_id),
+  FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (resolved_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX reports_open_idx ON reports (post_id) WHERE resolved_at IS NULL;
+
+-- every decision taken on a reported post, moderator_id is NULL for the automatic ones.
+-- It is the audit trail of the moderation, post_id has no foreign key so it is kept after the post is purged
+CREATE TABLE moderation_actions(
+  id VARCHAR(32) PRIMARY KEY,
+  post_id VARCHAR(32) NOT NULL,
+  author_id VARCHAR(32) NOT NULL,
+  moderator_id VARCHAR(32),
+  action VARCHAR(16) NOT NULL,
+  note VARCHAR(500) NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE,
+  FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX moderation_actions_created_at_idx ON moderation_actions (created_at DESC, id DESC);
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+)
+
+// columns of the users read by GetUserById and UpdateUser, the password is never selected with them
+const userProfileColumns = "id, email, COALESCE(username, ''), display_name, bio, avatar_url, role, suspended_at, created_at"
+
+// scanUser reads the userProfileColumns of a row
+func scanUser(row scanner, user *models.User) error {
+	var suspendedAt sql.NullTime
+	if err := row.Scan(&user.Id, &user.Email, &user.Username, &user.DisplayName, &user.Bio, &user.AvatarUrl, &user.Role, &suspendedAt, &user.CreatedAt); err != nil {
+		return err
+	}
+	user.SuspendedAt = nullTime(suspendedAt)
+	return nil
+}
+
+// UpdateUser only sets the profile fields present in the patch and returns the user after the change.
+// It returns ErrConflict when the username is used by another user
+func (repo *PostgresRepository) UpdateUser(ctx context.Context, id string, patch *models.UserPatch) (*models.User, error) {
+	if patch.IsEmpty() {
+		return repo.GetUserById(ctx, id)
+	}
+
+	var sets []string
+	var args []interface{}
+
+	set := func(column string, value string) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if patch.Username != nil {
+		args = append(args, *patch.Username)
+		sets = append(sets, fmt.Sprintf("username = NULLIF($%d, '')", len(args)))
+	}
+	if patch.DisplayName != nil {
+		set("display_name", *patch.DisplayName)
+	}
+	if patch.Bio != nil {
+		set("bio", *patch.Bio)
+	}
+	if patch.AvatarUrl != nil {
+		set("avatar_url", *patch.AvatarUrl)
+	}
+	args = append(args, id)
+	statement := fmt.Sprintf("UPDATE users SET %s WHERE id = $%d RETURNING %s", strings.Join(sets, ", "), len(args), userProfileColumns)
+
+	var user = models.User{}
+	err := scanUser(repo.db.QueryRowContext(ctx, statement, args...), &user)
+	if err == sql.ErrNoRows {
+		return nil, repository.ErrNotFound
+	}
+	if isUniqueViolation(err) {
+		return nil, repository.ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (repo *PostgresRepository) GetUserStats(ctx context.Context, id string) (*models.UserStats, error) {
+	var stats = models.UserStats{}
+
+	err := repo.db.QueryRowContext(ctx, `SELECT
+			(SELECT COUNT(*) FROM follows WHERE followee_id = $1),
+			(SELECT COUNT(*) FROM follows WHERE follower_id = $1)`, id).Scan(&stats.FollowerCount, &stats.FollowingCount)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,165 @@
+package diff
+
+import "strings"
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+const (
+	// when the texts need more edits than this, the diff is a full replacement
+	// instead of spending memory looking for the shortest one. The trace keeps
+	// about MAX_EDIT_DISTANCE^2 ints, ~320KB with 200
+	MAX_EDIT_DISTANCE = 200
+	// when the changed part of the texts has more lines than this, the diff is a full
+	// replacement, every edit walks the lines so the time grows with both limits
+	MAX_LINES = 2000
+)
+
+type Line struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines returns the changes needed to go from a to b, line by line.
+// It uses the Myers algorithm so it is fast when both texts are similar
+func Lines(a string, b string) []Line {
+	linesA, linesB := splitLines(a), splitLines(b)
+
+	// the lines in common at the start and at the end are left out of the search
+	prefix := 0
+	for prefix < len(linesA) && prefix < len(linesB) && linesA[prefix] == linesB[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(linesA)-prefix && suffix < len(linesB)-prefix &&
+		linesA[len(linesA)-1-suffix] == linesB[len(linesB)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(linesA)+len(linesB)-prefix-suffix)
+	for _, text := range linesA[:prefix] {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	lines = append(lines, diff(linesA[prefix:len(linesA)-suffix], linesB[prefix:len(linesB)-suffix])...)
+	for _, text := range linesA[len(linesA)-suffix:] {
+		lines = append(lines, Line{Op: Equal, Text: text})
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// trace keeps, for every number of edits d, the furthest x reached in each diagonal k
+// between -d-1 and d+1
+type trace [][]int
+
+func (t trace) at(d int, k int) int {
+	return t[d][k+d+1]
+}
+
+func diff(a []string, b []string) []Line {
+	n, m := len(a), len(b)
+	if n+m > MAX_LINES {
+		return replace(a, b)
+	}
+	max := n + m
+	if max > MAX_EDIT_DISTANCE {
+		max = MAX_EDIT_DISTANCE
+	}
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var t trace
+
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				t = append(t, append([]int(nil), v[offset-d-1:offset+d+2]...))
+				return backtrack(a, b, t)
+			}
+		}
+		t = append(t, append([]int(nil), v[offset-d-1:offset+d+2]...))
+	}
+
+	return replace(a, b)
+}
+
+// backtrack walks the trace from the end to the start in order to build the changes
+func backtrack(a []string, b []string, t trace) []Line {
+	var lines []Line
+	x, y := len(a), len(b)
+
+	for d := len(t) - 1; d >= 0 && (x > 0 || y > 0); d-- {
+		k := x - y
+
+		prevK := k
+		if d > 0 {
+			if k == -d || (k != d && t.at(d-1, k-1) < t.at(d-1, k+1)) {
+				prevK = k + 1
+			} else {
+				prevK = k - 1
+			}
+		}
+
+		prevX := 0
+		if d > 0 {
+			prevX = t.at(d-1, prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, Line{Op: Equal, Text: a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				lines = append(lines, Line{Op: Insert, Text: b[y]})
+			} else {
+				x--
+				lines = append(lines, Line{Op: Delete, Text: a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// replace deletes every line of a and inserts every line of b
+func replace(a []string, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	for _, text := range a {
+		lines = append(lines, Line{Op: Delete, Text: text})
+	}
+	for _, text := range b {
+		lines = append(lines, Line{Op: Insert, Text: text})
+	}
+	return lines
+}
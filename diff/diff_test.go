@@ -0,0 +1,130 @@
+package diff
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []Line
+	}{
+		{
+			name: "both empty",
+			a:    "",
+			b:    "",
+			want: []Line{},
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "one\ntwo\n",
+			want: []Line{{Insert, "one"}, {Insert, "two"}},
+		},
+		{
+			name: "to empty",
+			a:    "one\ntwo",
+			b:    "",
+			want: []Line{{Delete, "one"}, {Delete, "two"}},
+		},
+		{
+			name: "equal",
+			a:    "one\ntwo",
+			b:    "one\ntwo\n",
+			want: []Line{{Equal, "one"}, {Equal, "two"}},
+		},
+		{
+			name: "insert",
+			a:    "one\nthree",
+			b:    "one\ntwo\nthree",
+			want: []Line{{Equal, "one"}, {Insert, "two"}, {Equal, "three"}},
+		},
+		{
+			name: "delete",
+			a:    "one\ntwo\nthree",
+			b:    "one\nthree",
+			want: []Line{{Equal, "one"}, {Delete, "two"}, {Equal, "three"}},
+		},
+		{
+			name: "replace",
+			a:    "one\ntwo\nthree",
+			b:    "one\n2\nthree",
+			want: []Line{{Equal, "one"}, {Delete, "two"}, {Insert, "2"}, {Equal, "three"}},
+		},
+		{
+			name: "changes in the middle",
+			a:    "a\nb\nc\nd\ne",
+			b:    "a\nc\nx\nd\ne",
+			want: []Line{{Equal, "a"}, {Delete, "b"}, {Equal, "c"}, {Insert, "x"}, {Equal, "d"}, {Equal, "e"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Lines(test.a, test.b); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Lines(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+			}
+		})
+	}
+}
+
+// numbered returns count lines, each one with its number and the prefix
+func numbered(prefix string, count int) []string {
+	lines := make([]string, count)
+	for i := range lines {
+		lines[i] = prefix + strconv.Itoa(i)
+	}
+	return lines
+}
+
+func TestLinesOverTheCaps(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+	}{
+		{
+			name: "edit distance",
+			a:    numbered("a", MAX_EDIT_DISTANCE/2+1),
+			b:    numbered("b", MAX_EDIT_DISTANCE/2+1),
+		},
+		{
+			name: "lines",
+			a:    numbered("a", MAX_LINES/2+1),
+			b:    append(numbered("a", MAX_LINES/2), "b"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// a line that changes at the start and at the end so nothing is trimmed
+			a := append(append([]string{"first"}, test.a...), "last")
+			b := append(append([]string{"1st"}, test.b...), "end")
+
+			got := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+			if want := replace(a, b); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %d lines, want the %d of a full replacement", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestLinesTrimsTheCommonLines(t *testing.T) {
+	// the lines in common don't count for the caps
+	common := numbered("common", MAX_LINES)
+	a := append(append([]string(nil), common...), "old")
+	b := append(append([]string(nil), common...), "new")
+
+	got := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	if len(got) != len(common)+2 {
+		t.Fatalf("got %d lines, want %d", len(got), len(common)+2)
+	}
+	if tail := got[len(common):]; !reflect.DeepEqual(tail, []Line{{Delete, "old"}, {Insert, "new"}}) {
+		t.Errorf("got %v at the end", tail)
+	}
+}
@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+type Collection struct {
+	Id            string    `json:"id"`
+	Name          string    `json:"name"`
+	CreatedAt     time.Time `json:"created_at"`
+	BookmarkCount int       `json:"bookmark_count"`
+}
+
+func NewCollection(collection *models.BookmarkCollection) Collection {
+	return Collection{
+		Id:            collection.Id,
+		Name:          collection.Name,
+		CreatedAt:     collection.CreatedAt,
+		BookmarkCount: collection.BookmarkCount,
+	}
+}
+
+func NewCollections(collections []*models.BookmarkCollection) []Collection {
+	mapped := make([]Collection, len(collections))
+	for i, collection := range collections {
+		mapped[i] = NewCollection(collection)
+	}
+	return mapped
+}
+
+// Bookmark has the saved post while it is available, when the post is deleted or the user
+// can't see it anymore Available is false and Post is null
+type Bookmark struct {
+	PostId       string    `json:"post_id"`
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+	Available    bool      `json:"available"`
+	Post         *Post     `json:"post"`
+}
+
+func NewBookmark(bookmark *models.Bookmark, post *Post) Bookmark {
+	return Bookmark{
+		PostId:       bookmark.PostId,
+		BookmarkedAt: bookmark.CreatedAt,
+		Available:    post != nil,
+		Post:         post,
+	}
+}
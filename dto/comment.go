@@ -0,0 +1,50 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+type Comment struct {
+	Id             string    `json:"id"`
+	PostId         string    `json:"post_id"`
+	ParentId       *string   `json:"parent_id"`
+	UserId         string    `json:"user_id"`
+	CommentContent string    `json:"comment_content"`
+	CommentHtml    string    `json:"comment_html"`
+	CreatedAt      time.Time `json:"created_at"`
+	ReplyCount     int       `json:"reply_count"`
+	Replies        []Comment `json:"replies,omitempty"`
+}
+
+// NewComment maps the comment and the replies loaded below it
+func NewComment(comment *models.Comment) Comment {
+	return Comment{
+		Id:             comment.Id,
+		PostId:         comment.PostId,
+		ParentId:       comment.ParentId,
+		UserId:         comment.UserId,
+		CommentContent: comment.CommentContent,
+		CommentHtml:    comment.CommentHtml,
+		CreatedAt:      comment.CreatedAt,
+		ReplyCount:     comment.ReplyCount,
+		Replies:        newReplies(comment.Replies),
+	}
+}
+
+func NewComments(comments []*models.Comment) []Comment {
+	mapped := make([]Comment, len(comments))
+	for i, comment := range comments {
+		mapped[i] = NewComment(comment)
+	}
+	return mapped
+}
+
+// newReplies keeps the replies nil when there are none, so they are omitted
+func newReplies(replies []*models.Comment) []Comment {
+	if len(replies) == 0 {
+		return nil
+	}
+	return NewComments(replies)
+}
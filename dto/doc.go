@@ -0,0 +1,5 @@
+// Package dto has the representations of the resources sent to the clients, by the handlers
+// and through the websocket. The storage models are never serialized directly: every field
+// that leaves the server is listed in one of these types, so a new column (or the password
+// hash) can't be exposed by only changing a query.
+package dto
@@ -0,0 +1,15 @@
+package dto
+
+// RepostEvent is sent to the author of a post when it is reposted or quoted
+type RepostEvent struct {
+	PostId   string `json:"post_id"`
+	RepostId string `json:"repost_id"`
+	UserId   string `json:"user_id"`
+	Quote    bool   `json:"quote"`
+}
+
+// MentionEvent is sent only to the user mentioned in a post
+type MentionEvent struct {
+	PostId   string `json:"post_id"`
+	AuthorId string `json:"author_id"`
+}
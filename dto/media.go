@@ -0,0 +1,52 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+// MediaVariant is a resized copy of an image, its url is signed like the one of the media
+type MediaVariant struct {
+	ContentType  string    `json:"content_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Url          string    `json:"url"`
+	UrlExpiresAt time.Time `json:"url_expires_at"`
+}
+
+// Media has a signed url to download the file, it must be requested again once UrlExpiresAt passes
+type Media struct {
+	Id           string    `json:"id"`
+	ContentType  string    `json:"content_type"`
+	FileName     string    `json:"file_name"`
+	Size         int64     `json:"size"`
+	Url          string    `json:"url"`
+	UrlExpiresAt time.Time `json:"url_expires_at"`
+	PostId       *string   `json:"post_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	// the variants of the images are generated in the background once they are attached to a post,
+	// they are in Variants by their name (thumbnail, medium) when the status is ready
+	Status   models.MediaStatus      `json:"status"`
+	Variants map[string]MediaVariant `json:"variants"`
+}
+
+// NewMedia maps the media with its signed urls, variants can be nil
+func NewMedia(media *models.Media, url string, expiresAt time.Time, variants map[string]MediaVariant) Media {
+	if variants == nil {
+		variants = map[string]MediaVariant{}
+	}
+
+	return Media{
+		Id:           media.Id,
+		ContentType:  media.ContentType,
+		FileName:     media.FileName,
+		Size:         media.Size,
+		Url:          url,
+		UrlExpiresAt: expiresAt,
+		PostId:       media.PostId,
+		CreatedAt:    media.CreatedAt,
+		Status:       media.Status,
+		Variants:     variants,
+	}
+}
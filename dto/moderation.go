@@ -0,0 +1,88 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+// Report is returned to the user that reported the post, the reporters are never shown to the authors
+type Report struct {
+	Id        string    `json:"id"`
+	PostId    string    `json:"post_id"`
+	Reason    string    `json:"reason"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewReport(report *models.Report) Report {
+	return Report{
+		Id:        report.Id,
+		PostId:    report.PostId,
+		Reason:    report.Reason,
+		Details:   report.Details,
+		CreatedAt: report.CreatedAt,
+	}
+}
+
+// ReportedPost is an item of the moderation queue, only visible to the moderators
+type ReportedPost struct {
+	PostId          string         `json:"post_id"`
+	AuthorId        string         `json:"author_id"`
+	PostContent     string         `json:"post_content"`
+	PostHtml        string         `json:"post_html"`
+	HiddenAt        *time.Time     `json:"hidden_at"`
+	Reports         int            `json:"reports"`
+	Reasons         map[string]int `json:"reasons"`
+	FirstReportedAt time.Time      `json:"first_reported_at"`
+	LastReportedAt  time.Time      `json:"last_reported_at"`
+}
+
+func NewReportedPosts(posts []*models.ReportedPost) []ReportedPost {
+	reported := make([]ReportedPost, len(posts))
+	for i, post := range posts {
+		reported[i] = ReportedPost{
+			PostId:          post.PostId,
+			AuthorId:        post.AuthorId,
+			PostContent:     post.PostContent,
+			PostHtml:        post.PostHtml,
+			HiddenAt:        post.HiddenAt,
+			Reports:         post.Reports,
+			Reasons:         post.Reasons,
+			FirstReportedAt: post.FirstReportedAt,
+			LastReportedAt:  post.LastReportedAt,
+		}
+	}
+	return reported
+}
+
+// ModerationAction is an entry of the record of the moderation, moderator_id is null for the automatic ones
+type ModerationAction struct {
+	Id          string                      `json:"id"`
+	PostId      string                      `json:"post_id"`
+	AuthorId    string                      `json:"author_id"`
+	ModeratorId *string                     `json:"moderator_id"`
+	Action      models.ModerationActionKind `json:"action"`
+	Note        string                      `json:"note"`
+	CreatedAt   time.Time                   `json:"created_at"`
+}
+
+func NewModerationAction(action *models.ModerationAction) ModerationAction {
+	return ModerationAction{
+		Id:          action.Id,
+		PostId:      action.PostId,
+		AuthorId:    action.AuthorId,
+		ModeratorId: action.ModeratorId,
+		Action:      action.Action,
+		Note:        action.Note,
+		CreatedAt:   action.CreatedAt,
+	}
+}
+
+func NewModerationActions(actions []*models.ModerationAction) []ModerationAction {
+	mapped := make([]ModerationAction, len(actions))
+	for i, action := range actions {
+		mapped[i] = NewModerationAction(action)
+	}
+	return mapped
+}
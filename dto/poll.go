@@ -0,0 +1,60 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+type PollOption struct {
+	Id    string `json:"id"`
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+type Poll struct {
+	Multiple bool         `json:"multiple"`
+	ClosesAt *time.Time   `json:"closes_at"`
+	Closed   bool         `json:"closed"`
+	Voters   int          `json:"voters"`
+	Options  []PollOption `json:"options"`
+	// ids of the options voted by the current user
+	Mine []string `json:"mine"`
+}
+
+func newPollOptions(poll *models.Poll) []PollOption {
+	options := make([]PollOption, len(poll.Options))
+	for i, option := range poll.Options {
+		options[i] = PollOption{Id: option.Id, Text: option.Text, Votes: option.Votes}
+	}
+	return options
+}
+
+func NewPoll(poll *models.Poll) Poll {
+	return Poll{
+		Multiple: poll.Multiple,
+		ClosesAt: poll.ClosesAt,
+		Closed:   poll.IsClosed(time.Now()),
+		Voters:   poll.Voters,
+		Options:  newPollOptions(poll),
+		Mine:     poll.Mine,
+	}
+}
+
+// PollEvent has the tallies of a poll, it is sent to the subscribers of the post
+// on every vote and when the poll is closed
+type PollEvent struct {
+	PostId  string       `json:"post_id"`
+	Closed  bool         `json:"closed"`
+	Voters  int          `json:"voters"`
+	Options []PollOption `json:"options"`
+}
+
+func NewPollEvent(poll *models.Poll) PollEvent {
+	return PollEvent{
+		PostId:  poll.PostId,
+		Closed:  poll.IsClosed(time.Now()),
+		Voters:  poll.Voters,
+		Options: newPollOptions(poll),
+	}
+}
@@ -0,0 +1,126 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+type ReactionSummary struct {
+	Counts map[string]int `json:"counts"`
+	Mine   []string       `json:"mine"`
+}
+
+// NewReactionSummary returns an empty summary for the posts without reactions
+func NewReactionSummary(summary *models.ReactionSummary) ReactionSummary {
+	if summary == nil {
+		return ReactionSummary{Counts: map[string]int{}, Mine: []string{}}
+	}
+	return ReactionSummary{Counts: summary.Counts, Mine: summary.Mine}
+}
+
+// PostDetails are the aggregates and the related resources shown with a post, the nil ones are empty
+type PostDetails struct {
+	Comments  int
+	Reactions *models.ReactionSummary
+	Reposts   *models.RepostCounts
+	Poll      *models.Poll
+	// already signed, the models don't have the urls
+	Media []Media
+}
+
+type Post struct {
+	Id          string     `json:"id"`
+	PostContent string     `json:"post_content"`
+	PostHtml    string     `json:"post_html"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Revision    int        `json:"revision"`
+	UserId      string     `json:"user_id"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	// only the author sees the hidden posts
+	HiddenAt     *time.Time            `json:"hidden_at,omitempty"`
+	Status       models.PostStatus     `json:"status"`
+	PublishAt    *time.Time            `json:"publish_at"`
+	PublishedAt  *time.Time            `json:"published_at"`
+	Visibility   models.PostVisibility `json:"visibility"`
+	CommentCount int                   `json:"comment_count"`
+	Reactions    ReactionSummary       `json:"reactions"`
+	RepostCount  int                   `json:"repost_count"`
+	QuoteCount   int                   `json:"quote_count"`
+	// set for the reposts and quotes, RepostOf is null when the original post was deleted
+	// or the user can't see it
+	RepostOfId *string `json:"repost_of_id"`
+	RepostOf   *Post   `json:"repost_of"`
+	// null for the posts without poll
+	Poll  *Poll   `json:"poll"`
+	Media []Media `json:"media"`
+}
+
+// NewPost maps the post with its details, the original of the reposts is embedded by the caller
+func NewPost(post *models.Post, details PostDetails) Post {
+	mapped := Post{
+		Id:           post.Id,
+		PostContent:  post.PostContent,
+		PostHtml:     post.PostHtml,
+		CreatedAt:    post.CreatedAt,
+		UpdatedAt:    post.UpdatedAt,
+		Revision:     post.Revision,
+		UserId:       post.UserId,
+		DeletedAt:    post.DeletedAt,
+		HiddenAt:     post.HiddenAt,
+		Status:       post.Status,
+		PublishAt:    post.PublishAt,
+		PublishedAt:  post.PublishedAt,
+		Visibility:   post.Visibility,
+		CommentCount: details.Comments,
+		Reactions:    NewReactionSummary(details.Reactions),
+		RepostOfId:   post.RepostOfId,
+		Media:        details.Media,
+	}
+	if mapped.Media == nil {
+		mapped.Media = []Media{}
+	}
+	if details.Reposts != nil {
+		mapped.RepostCount = details.Reposts.Reposts
+		mapped.QuoteCount = details.Reposts.Quotes
+	}
+	if details.Poll != nil {
+		poll := NewPoll(details.Poll)
+		mapped.Poll = &poll
+	}
+	return mapped
+}
+
+// SearchResult is a post matching a search, with its relevance and the fragments that matched
+type SearchResult struct {
+	Post
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type PostRevision struct {
+	PostId      string    `json:"post_id"`
+	Revision    int       `json:"revision"`
+	PostContent string    `json:"post_content"`
+	PostHtml    string    `json:"post_html"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func NewPostRevision(revision *models.PostRevision) PostRevision {
+	return PostRevision{
+		PostId:      revision.PostId,
+		Revision:    revision.Revision,
+		PostContent: revision.PostContent,
+		PostHtml:    revision.PostHtml,
+		CreatedAt:   revision.CreatedAt,
+	}
+}
+
+func NewPostRevisions(revisions []*models.PostRevision) []PostRevision {
+	mapped := make([]PostRevision, len(revisions))
+	for i, revision := range revisions {
+		mapped[i] = NewPostRevision(revision)
+	}
+	return mapped
+}
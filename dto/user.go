@@ -0,0 +1,77 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+// Me is the profile of the current user, it is the only representation that includes the email
+type Me struct {
+	Id          string `json:"id"`
+	Email       string `json:"email"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarUrl   string `json:"avatar_url"`
+	// user or moderator
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewMe(user *models.User) Me {
+	return Me{
+		Id:          user.Id,
+		Email:       user.Email,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+		Role:        user.Role,
+		CreatedAt:   user.CreatedAt,
+	}
+}
+
+// Profile is the public profile of an user, visible to every user
+type Profile struct {
+	Id             string    `json:"id"`
+	Username       string    `json:"username"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	AvatarUrl      string    `json:"avatar_url"`
+	CreatedAt      time.Time `json:"created_at"`
+	FollowerCount  int       `json:"follower_count"`
+	FollowingCount int       `json:"following_count"`
+}
+
+func NewProfile(user *models.User, stats *models.UserStats) Profile {
+	return Profile{
+		Id:             user.Id,
+		Username:       user.Username,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		AvatarUrl:      user.AvatarUrl,
+		CreatedAt:      user.CreatedAt,
+		FollowerCount:  stats.FollowerCount,
+		FollowingCount: stats.FollowingCount,
+	}
+}
+
+// RelatedUser is an item of the followers, following, blocked or muted lists
+type RelatedUser struct {
+	UserId    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewRelatedUsers(users []*models.RelatedUser) []RelatedUser {
+	related := make([]RelatedUser, len(users))
+	for i, user := range users {
+		related[i] = RelatedUser{
+			UserId:    user.UserId,
+			Username:  user.Username,
+			CreatedAt: user.CreatedAt,
+		}
+	}
+	return related
+}
@@ -0,0 +1,115 @@
+package dto
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+const (
+	testEmail    = "someone@example.com"
+	testPassword = "$2a$08$secret.password.hash"
+)
+
+func newTestUser() *models.User {
+	return &models.User{
+		Id:          "user-1",
+		Email:       testEmail,
+		Username:    "someone",
+		Password:    testPassword,
+		DisplayName: "Someone",
+		Bio:         "bio",
+		CreatedAt:   time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+// jsonKeys returns every key of the encoded value, including the ones of the nested objects and arrays
+func jsonKeys(t *testing.T, encoded []byte) map[string]bool {
+	t.Helper()
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("decoding %s: %v", encoded, err)
+	}
+
+	keys := map[string]bool{}
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch value := value.(type) {
+		case map[string]interface{}:
+			for key, nested := range value {
+				keys[key] = true
+				walk(nested)
+			}
+		case []interface{}:
+			for _, nested := range value {
+				walk(nested)
+			}
+		}
+	}
+	walk(decoded)
+	return keys
+}
+
+// assertSerialized checks that the password is never in the encoded value, and the email only when it is expected
+func assertSerialized(t *testing.T, value interface{}, emailExpected bool) {
+	t.Helper()
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+	keys := jsonKeys(t, encoded)
+
+	if keys["password"] || strings.Contains(string(encoded), testPassword) {
+		t.Errorf("the password is serialized: %s", encoded)
+	}
+	if hasEmail := keys["email"] && strings.Contains(string(encoded), testEmail); hasEmail != emailExpected {
+		t.Errorf("email serialized = %t, want %t: %s", hasEmail, emailExpected, encoded)
+	}
+	if !emailExpected && strings.Contains(string(encoded), testEmail) {
+		t.Errorf("the email is serialized: %s", encoded)
+	}
+}
+
+func TestUserRepresentations(t *testing.T) {
+	user := newTestUser()
+
+	tests := []struct {
+		name          string
+		value         interface{}
+		emailExpected bool
+	}{
+		{"Me", NewMe(user), true},
+		{"Profile", NewProfile(user, &models.UserStats{FollowerCount: 1, FollowingCount: 2}), false},
+		{"RelatedUsers", NewRelatedUsers([]*models.RelatedUser{{UserId: user.Id, Username: user.Username, CreatedAt: user.CreatedAt}}), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertSerialized(t, test.value, test.emailExpected)
+		})
+	}
+}
+
+func TestUserModelExcludesCredentials(t *testing.T) {
+	encoded, err := json.Marshal(newTestUser())
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+
+	keys := jsonKeys(t, encoded)
+	for _, field := range []string{"Email", "Password", "email", "password"} {
+		if keys[field] {
+			t.Errorf("%s is serialized: %s", field, encoded)
+		}
+	}
+	for _, value := range []string{testEmail, testPassword} {
+		if strings.Contains(string(encoded), value) {
+			t.Errorf("%q is serialized: %s", value, encoded)
+		}
+	}
+}
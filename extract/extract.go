@@ -0,0 +1,35 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// a tag or a mention starts after a space or a punctuation mark, so emails and urls with # are not taken
+	tagPattern     = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/#])#([\p{L}\p{N}_]{1,64})`)
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@/])@([A-Za-z0-9_]{3,30})`)
+)
+
+// Tags returns the #tags of the content, lowercased and without duplicates
+func Tags(content string) []string {
+	return unique(tagPattern.FindAllStringSubmatch(content, -1))
+}
+
+// Mentions returns the @usernames of the content, lowercased and without duplicates
+func Mentions(content string) []string {
+	return unique(mentionPattern.FindAllStringSubmatch(content, -1))
+}
+
+func unique(matches [][]string) []string {
+	seen := map[string]bool{}
+	values := []string{}
+	for _, match := range matches {
+		value := strings.ToLower(match[1])
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -3,11 +3,20 @@ module github.com/emavillamayorpsh/rest-ws
 go 1.19
 
 require (
-	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
-	github.com/gorilla/mux v1.8.0 // indirect
-	github.com/gorilla/websocket v1.5.0 // indirect
-	github.com/joho/godotenv v1.4.0 // indirect
-	github.com/lib/pq v1.10.7 // indirect
-	github.com/segmentio/ksuid v1.0.4 // indirect
-	golang.org/x/crypto v0.1.0 // indirect
+	github.com/golang-jwt/jwt v3.2.2+incompatible
+	github.com/gorilla/mux v1.8.0
+	github.com/gorilla/websocket v1.5.0
+	github.com/joho/godotenv v1.4.0
+	github.com/lib/pq v1.10.7
+	github.com/microcosm-cc/bluemonday v1.0.23
+	github.com/segmentio/ksuid v1.0.4
+	github.com/yuin/goldmark v1.5.6
+	golang.org/x/crypto v0.1.0
+	golang.org/x/image v0.18.0
+)
+
+require (
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/gorilla/css v1.0.0 // indirect
+	golang.org/x/net v0.8.0 // indirect
 )
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+)
+
+// checkNotBlocked returns ErrForbidden when the owner of the post, or the followed user, blocked the current user
+func checkNotBlocked(r *http.Request, ownerId string) error {
+	blocked, err := repository.IsBlocked(r.Context(), ownerId, requestctx.UserId(r.Context()))
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return repository.ErrForbidden
+	}
+	return nil
+}
+
+// changeRelation runs the block, unblock, mute or unmute of the user of the url
+func changeRelation(w http.ResponseWriter, r *http.Request, change func(r *http.Request, userId string, otherId string) error) {
+	params := mux.Vars(r)
+	userId := requestctx.UserId(r.Context())
+
+	if params["id"] == userId {
+		apierror.Write(w, r, apierror.InvalidParam("id", "it can't be your own user"))
+		return
+	}
+
+	if err := change(r, userId, params["id"]); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func BlockHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		changeRelation(w, r, func(r *http.Request, userId string, otherId string) error {
+			return repository.Block(r.Context(), userId, otherId)
+		})
+	}
+}
+
+func UnblockHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		changeRelation(w, r, func(r *http.Request, userId string, otherId string) error {
+			return repository.Unblock(r.Context(), userId, otherId)
+		})
+	}
+}
+
+func MuteHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		changeRelation(w, r, func(r *http.Request, userId string, otherId string) error {
+			return repository.Mute(r.Context(), userId, otherId)
+		})
+	}
+}
+
+func UnmuteHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		changeRelation(w, r, func(r *http.Request, userId string, otherId string) error {
+			return repository.Unmute(r.Context(), userId, otherId)
+		})
+	}
+}
+
+// listRelatedUsers answers with a page of the users blocked or muted by the current user
+func listRelatedUsers(w http.ResponseWriter, r *http.Request, list func(r *http.Request, query *models.UserListQuery) ([]*models.RelatedUser, error)) {
+	limit, err := parseLimit(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	cursor, err := parseCursor(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// ask for one extra user in order to know if there is a next page
+	users, err := list(r, &models.UserListQuery{
+		UserId: requestctx.UserId(r.Context()),
+		Cursor: cursor,
+		Limit:  limit + 1,
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	writeRelatedUsers(w, r, users, limit)
+}
+
+// writeRelatedUsers answers with a page of users, users has one more user than limit when there is a next page
+func writeRelatedUsers(w http.ResponseWriter, r *http.Request, users []*models.RelatedUser, limit uint64) {
+	var nextCursor string
+	if uint64(len(users)) > limit {
+		users = users[:limit]
+		last := users[len(users)-1]
+		nextCursor = (&models.Cursor{CreatedAt: last.CreatedAt, Id: last.UserId}).Encode()
+	}
+
+	setNextLink(w, r, nextCursor, limit)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PageResponse{
+		Data:       dto.NewRelatedUsers(users),
+		NextCursor: nextCursor,
+	})
+}
+
+func ListBlockedUsersHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listRelatedUsers(w, r, func(r *http.Request, query *models.UserListQuery) ([]*models.RelatedUser, error) {
+			return repository.ListBlockedUsers(r.Context(), query)
+		})
+	}
+}
+
+func ListMutedUsersHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listRelatedUsers(w, r, func(r *http.Request, query *models.UserListQuery) ([]*models.RelatedUser, error) {
+			return repository.ListMutedUsers(r.Context(), query)
+		})
+	}
+}
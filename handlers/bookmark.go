@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	MAX_COLLECTION_NAME_LENGTH = 100
+)
+
+type CollectionRequest struct {
+	Name string `json:"name"`
+}
+
+// parseCollectionRequest reads the body of the create and rename of collections, the name is trimmed
+func parseCollectionRequest(r *http.Request) (string, error) {
+	var request = CollectionRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return "", apierror.InvalidJSON(err)
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return "", apierror.Validation(apierror.FieldError{Field: "name", Message: "it is required"})
+	}
+	if utf8.RuneCountInString(name) > MAX_COLLECTION_NAME_LENGTH {
+		return "", apierror.Validation(apierror.FieldError{Field: "name", Message: fmt.Sprintf("it can't have more than %d characters", MAX_COLLECTION_NAME_LENGTH)})
+	}
+	return name, nil
+}
+
+// writeCollectionError answers with a specific message when the name is already used
+func writeCollectionError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, repository.ErrConflict) {
+		apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "You already have a collection with this name").WithCause(err))
+		return
+	}
+	apierror.Write(w, r, err)
+}
+
+func InsertCollectionHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, err := parseCollectionRequest(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		id, err := ksuid.NewRandom()
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		collection := models.BookmarkCollection{
+			Id:     id.String(),
+			UserId: requestctx.UserId(r.Context()),
+			Name:   name,
+		}
+
+		if err := repository.InsertCollection(r.Context(), &collection); err != nil {
+			writeCollectionError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(dto.NewCollection(&collection))
+	}
+}
+
+func ListCollectionsHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, err := parseLimit(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		cursor, err := parseCursor(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// ask for one extra collection in order to know if there is a next page
+		collections, err := repository.ListCollections(r.Context(), &models.CollectionQuery{
+			UserId: requestctx.UserId(r.Context()),
+			Cursor: cursor,
+			Limit:  limit + 1,
+		})
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var nextCursor string
+		if uint64(len(collections)) > limit {
+			collections = collections[:limit]
+			last := collections[len(collections)-1]
+			nextCursor = (&models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id}).Encode()
+		}
+
+		setNextLink(w, r, nextCursor, limit)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PageResponse{
+			Data:       dto.NewCollections(collections),
+			NextCursor: nextCursor,
+		})
+	}
+}
+
+func RenameCollectionHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		name, err := parseCollectionRequest(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		collection, err := repository.RenameCollection(r.Context(), requestctx.UserId(r.Context()), params["id"], name)
+		if err != nil {
+			writeCollectionError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dto.NewCollection(collection))
+	}
+}
+
+func DeleteCollectionHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		if err := repository.DeleteCollection(r.Context(), requestctx.UserId(r.Context()), params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AddBookmarkHandler saves a post in a collection, only the posts the user can see can be saved
+func AddBookmarkHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		userId := requestctx.UserId(r.Context())
+
+		if _, err := repository.GetCollection(r.Context(), userId, params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if _, err := repository.GetPostById(r.Context(), params["postId"], userId); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := repository.AddBookmark(r.Context(), params["id"], params["postId"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RemoveBookmarkHandler works with the posts that are not available anymore too
+func RemoveBookmarkHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		if _, err := repository.GetCollection(r.Context(), requestctx.UserId(r.Context()), params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := repository.RemoveBookmark(r.Context(), params["id"], params["postId"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ListBookmarksHandler lists the posts saved in a collection, the last saved first. The posts
+// that were deleted or can't be seen anymore are listed with only their id
+func ListBookmarksHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		userId := requestctx.UserId(r.Context())
+
+		limit, err := parseLimit(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		cursor, err := parseCursor(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if _, err := repository.GetCollection(r.Context(), userId, params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// ask for one extra bookmark in order to know if there is a next page
+		bookmarks, err := repository.ListBookmarks(r.Context(), &models.BookmarkQuery{
+			CollectionId: params["id"],
+			Cursor:       cursor,
+			Limit:        limit + 1,
+		})
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var nextCursor string
+		if uint64(len(bookmarks)) > limit {
+			bookmarks = bookmarks[:limit]
+			last := bookmarks[len(bookmarks)-1]
+			nextCursor = (&models.Cursor{CreatedAt: last.CreatedAt, Id: last.PostId}).Encode()
+		}
+
+		postIds := make([]string, len(bookmarks))
+		for i, bookmark := range bookmarks {
+			postIds[i] = bookmark.PostId
+		}
+
+		posts, err := repository.GetPostsByIds(r.Context(), postIds, userId)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		responses, err := newPostResponses(r, posts)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		available := map[string]*dto.Post{}
+		for i := range responses {
+			available[responses[i].Id] = &responses[i]
+		}
+
+		data := make([]dto.Bookmark, len(bookmarks))
+		for i, bookmark := range bookmarks {
+			data[i] = dto.NewBookmark(bookmark, available[bookmark.PostId])
+		}
+
+		setNextLink(w, r, nextCursor, limit)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PageResponse{
+			Data:       data,
+			NextCursor: nextCursor,
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/notify"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	// levels of replies returned below every comment
+	DEFAULT_COMMENT_DEPTH = 2
+	MAX_COMMENT_DEPTH     = 5
+)
+
+type InsertCommentRequest struct {
+	CommentContent string  `json:"comment_content"`
+	ParentId       *string `json:"parent_id"`
+}
+
+type UpdateCommentRequest struct {
+	CommentContent string `json:"comment_content"`
+}
+
+type CommentUpdateResponse struct {
+	Message string `json:"message"`
+}
+
+// parseDepth reads the "depth" query param, the levels of replies included in the response
+func parseDepth(r *http.Request) (int, error) {
+	depthStr := r.URL.Query().Get("depth")
+	if depthStr == "" {
+		return DEFAULT_COMMENT_DEPTH, nil
+	}
+
+	depth, err := strconv.Atoi(depthStr)
+	if err != nil || depth < 0 || depth > MAX_COMMENT_DEPTH {
+		return 0, apierror.InvalidParam("depth", "it must be a number between 0 and "+strconv.Itoa(MAX_COMMENT_DEPTH))
+	}
+	return depth, nil
+}
+
+// loadReplies fills the replies of the comments up to depth levels, comments deeper than that
+// only have their reply_count so the clients can ask for them with the parent_id param
+func loadReplies(r *http.Request, comments []*models.Comment, depth int) error {
+	byId := map[string]*models.Comment{}
+	parentIds := make([]string, len(comments))
+	for i, comment := range comments {
+		byId[comment.Id] = comment
+		parentIds[i] = comment.Id
+	}
+
+	replies, err := repository.ListCommentReplies(r.Context(), parentIds, depth, requestctx.UserId(r.Context()))
+	if err != nil {
+		return err
+	}
+
+	// replies are sorted by creation, so a parent is always loaded before its replies
+	for _, reply := range replies {
+		byId[reply.Id] = reply
+	}
+	for _, reply := range replies {
+		if parent, ok := byId[*reply.ParentId]; ok {
+			parent.Replies = append(parent.Replies, reply)
+		}
+	}
+	return nil
+}
+
+func InsertCommentHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		var commentRequest = InsertCommentRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&commentRequest); err != nil {
+			apierror.Write(w, r, apierror.InvalidJSON(err))
+			return
+		}
+
+		commentHtml, err := renderContent(s, "comment_content", commentRequest.CommentContent)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// the post must exist, its author must not have blocked the user and the parent must be a comment of the same post
+		post, err := repository.GetPostById(r.Context(), params["id"], requestctx.UserId(r.Context()))
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		if err := checkNotBlocked(r, post.UserId); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		if commentRequest.ParentId != nil {
+			_, err := repository.GetCommentById(r.Context(), params["id"], *commentRequest.ParentId)
+			if errors.Is(err, repository.ErrNotFound) {
+				apierror.Write(w, r, apierror.Validation(apierror.FieldError{Field: "parent_id", Message: "it is not a comment of this post"}))
+				return
+			}
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+		}
+
+		id, err := ksuid.NewRandom()
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		comment := models.Comment{
+			Id:             id.String(),
+			PostId:         params["id"],
+			ParentId:       commentRequest.ParentId,
+			UserId:         requestctx.UserId(r.Context()),
+			CommentContent: commentRequest.CommentContent,
+			CommentHtml:    commentHtml,
+		}
+
+		if err = repository.InsertComment(r.Context(), &comment); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// read it back in order to return the values generated by the db
+		created, err := repository.GetCommentById(r.Context(), comment.PostId, comment.Id)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := notify.CommentCreated(r.Context(), s.Hub(), created); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(dto.NewComment(created))
+	}
+}
+
+func GetCommentByIdHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		depth, err := parseDepth(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// the comments of the posts the user can't see are not found either
+		if _, err := repository.GetPostById(r.Context(), params["id"], requestctx.UserId(r.Context())); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		comment, err := repository.GetCommentById(r.Context(), params["id"], params["commentId"])
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := loadReplies(r, []*models.Comment{comment}, depth); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dto.NewComment(comment))
+	}
+}
+
+func ListCommentsHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		limit, err := parseLimit(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		depth, err := parseDepth(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		query := models.CommentQuery{
+			ViewerId: requestctx.UserId(r.Context()),
+			PostId:   params["id"],
+			ParentId: r.URL.Query().Get("parent_id"),
+			// ask for one extra comment in order to know if there is a next page
+			Limit: limit + 1,
+		}
+
+		if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+			if query.Cursor, err = models.DecodeCursor(cursorStr); err != nil {
+				apierror.Write(w, r, apierror.InvalidParam("cursor", "it must be a next_cursor returned by a previous page"))
+				return
+			}
+		}
+
+		if _, err := repository.GetPostById(r.Context(), query.PostId, requestctx.UserId(r.Context())); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		comments, err := repository.ListComments(r.Context(), &query)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var nextCursor string
+		if uint64(len(comments)) > limit {
+			comments = comments[:limit]
+			last := comments[len(comments)-1]
+			nextCursor = (&models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id}).Encode()
+		}
+
+		if err := loadReplies(r, comments, depth); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		setNextLink(w, r, nextCursor, limit)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PageResponse{
+			Data:       dto.NewComments(comments),
+			NextCursor: nextCursor,
+		})
+	}
+}
+
+func UpdateCommentHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		var commentRequest = UpdateCommentRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&commentRequest); err != nil {
+			apierror.Write(w, r, apierror.InvalidJSON(err))
+			return
+		}
+
+		commentHtml, err := renderContent(s, "comment_content", commentRequest.CommentContent)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		comment := models.Comment{
+			Id:             params["commentId"],
+			PostId:         params["id"],
+			UserId:         requestctx.UserId(r.Context()),
+			CommentContent: commentRequest.CommentContent,
+			CommentHtml:    commentHtml,
+		}
+
+		if err = repository.UpdateComment(r.Context(), &comment); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CommentUpdateResponse{
+			Message: "Comment Updated",
+		})
+	}
+}
+
+func DeleteCommentHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		err := repository.DeleteComment(r.Context(), params["id"], params["commentId"], requestctx.UserId(r.Context()))
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(CommentUpdateResponse{
+			Message: "Comment Deleted",
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/server"
+)
+
+// postETag identifies the representation of the post sent to the viewer. It starts with the revision,
+// the version of the content that If-Match is checked against, and ends with a hash of the post because
+// the rest of its fields (status, counters, poll, the reactions of the viewer) change without a new revision.
+// The signed urls of the media are not part of it, the clients request the post again when they expire.
+// The hash covers what only the viewer sees, like its reactions or its vote, so the tag varies per viewer.
+// GET loads the counters before answering 304: a tag of the revision alone would keep them stale
+func postETag(post dto.Post) (string, error) {
+	encoded, err := json.Marshal(withoutSignedUrls(post))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return fmt.Sprintf(`"%d-%s"`, post.Revision, hex.EncodeToString(sum[:12])), nil
+}
+
+// setPostETag sends the tag of the post, the caches keep a version per user because it varies per viewer
+func setPostETag(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Add("Vary", "Authorization")
+}
+
+// withoutSignedUrls copies the post without the urls of its media, they are signed again on every response
+func withoutSignedUrls(post dto.Post) dto.Post {
+	media := make([]dto.Media, len(post.Media))
+	for i, item := range post.Media {
+		variants := make(map[string]dto.MediaVariant, len(item.Variants))
+		for name, variant := range item.Variants {
+			variant.Url, variant.UrlExpiresAt = "", time.Time{}
+			variants[name] = variant
+		}
+		item.Url, item.UrlExpiresAt, item.Variants = "", time.Time{}, variants
+		media[i] = item
+	}
+	post.Media = media
+
+	if post.RepostOf != nil {
+		original := withoutSignedUrls(*post.RepostOf)
+		post.RepostOf = &original
+	}
+	return post
+}
+
+// splitETags reads the list of an If-Match or If-None-Match header
+func splitETags(header string) []string {
+	var etags []string
+	for _, etag := range strings.Split(header, ",") {
+		if etag = strings.TrimSpace(etag); etag != "" {
+			etags = append(etags, etag)
+		}
+	}
+	return etags
+}
+
+// notModified checks the If-None-Match header against the current ETag, weak tags are compared too
+func notModified(r *http.Request, etag string) bool {
+	for _, candidate := range splitETags(r.Header.Get("If-None-Match")) {
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// expectedRevisions reads the If-Match header, an empty list means that any revision can be updated.
+// When the server requires it, requests without the header are rejected with 428
+func expectedRevisions(s server.Server, r *http.Request) ([]int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		if s.Config().RequireIfMatch {
+			return nil, apierror.New(http.StatusPreconditionRequired, apierror.CodeIfMatch, "The If-Match header with the ETag of the post is required")
+		}
+		return nil, nil
+	}
+	if header == "*" {
+		return nil, nil
+	}
+
+	var revisions []int
+	for _, etag := range splitETags(header) {
+		// weak tags never match in If-Match
+		if strings.HasPrefix(etag, "W/") {
+			continue
+		}
+		// the tags of postETag, the ones with only the revision are accepted too
+		revisionStr, _, _ := strings.Cut(strings.Trim(etag, `"`), "-")
+		revision, err := strconv.Atoi(revisionStr)
+		if err == nil {
+			revisions = append(revisions, revision)
+		}
+	}
+
+	// none of the tags can be a version of the post
+	if len(revisions) == 0 {
+		revisions = []int{0}
+	}
+	return revisions, nil
+}
+
+// matchesRevision tells if the revision is one of the expected by If-Match, any revision matches an empty list
+func matchesRevision(expected []int, revision int) bool {
+	if len(expected) == 0 {
+		return true
+	}
+	for _, candidate := range expected {
+		if candidate == revision {
+			return true
+		}
+	}
+	return false
+}
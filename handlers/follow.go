@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+)
+
+func FollowHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		userId := requestctx.UserId(r.Context())
+
+		if params["id"] == userId {
+			apierror.Write(w, r, apierror.InvalidParam("id", "you can't follow yourself"))
+			return
+		}
+
+		if err := checkNotBlocked(r, params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := repository.Follow(r.Context(), userId, params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func UnfollowHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		if err := repository.Unfollow(r.Context(), requestctx.UserId(r.Context()), params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listFollows answers with a page of the followers, or the followed users, of the user of the url
+func listFollows(w http.ResponseWriter, r *http.Request, following bool) {
+	params := mux.Vars(r)
+
+	limit, err := parseLimit(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	cursor, err := parseCursor(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if _, err := repository.GetUserById(r.Context(), params["id"]); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// ask for one extra user in order to know if there is a next page
+	follows, err := repository.ListFollows(r.Context(), &models.FollowQuery{
+		UserId:    params["id"],
+		Following: following,
+		Cursor:    cursor,
+		Limit:     limit + 1,
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	writeRelatedUsers(w, r, follows, limit)
+}
+
+func ListFollowersHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listFollows(w, r, false)
+	}
+}
+
+func ListFollowingHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listFollows(w, r, true)
+	}
+}
+
+// TimelineHandler lists the posts of the users followed by the current user, and its own ones
+func TimelineHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, err := parseLimit(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		cursor, err := parseCursor(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// ask for one extra post in order to know if there is a next page
+		posts, err := repository.ListTimeline(r.Context(), &models.TimelineQuery{
+			UserId: requestctx.UserId(r.Context()),
+			Cursor: cursor,
+			Limit:  limit + 1,
+		})
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		writePosts(w, r, posts, limit)
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/imaging"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/emavillamayorpsh/rest-ws/storage"
+	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	MEDIA_FORM_FIELD = "file"
+	// the files bigger than this are kept in a temporary file while they are uploaded
+	MEDIA_FORM_MEMORY = 1 << 20
+	// room for the boundaries and headers of the multipart body
+	MEDIA_FORM_OVERHEAD = 1 << 20
+	MAX_MEDIA_FILE_NAME = 255
+	MAX_POST_MEDIA      = 4
+)
+
+// MediaTypes are the types that can be uploaded with the extension of their files, the type
+// is detected from the content, the one sent by the client is ignored
+var MediaTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+}
+
+// detectMediaType sniffs the first bytes of the file, it returns an empty type when it isn't allowed
+func detectMediaType(file multipart.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		return "", nil
+	}
+	if _, ok := MediaTypes[mediaType]; !ok {
+		return "", nil
+	}
+	return mediaType, nil
+}
+
+// mediaFileName keeps only the name of the file sent by the client, it is only shown to the users
+func mediaFileName(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "." || name == "/" {
+		return ""
+	}
+	for utf8.RuneCountInString(name) > MAX_MEDIA_FILE_NAME {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// newMediaResponse signs the urls of the media and of its variants
+func newMediaResponse(media *models.Media) (dto.Media, error) {
+	url, expiresAt, err := storage.SignedURL(media.StorageKey)
+	if err != nil {
+		return dto.Media{}, err
+	}
+
+	variants := map[string]dto.MediaVariant{}
+	for _, variant := range media.Variants {
+		variantUrl, variantExpiresAt, err := storage.SignedURL(variant.StorageKey)
+		if err != nil {
+			return dto.Media{}, err
+		}
+		variants[variant.Name] = dto.MediaVariant{
+			ContentType:  variant.ContentType,
+			Width:        variant.Width,
+			Height:       variant.Height,
+			Url:          variantUrl,
+			UrlExpiresAt: variantExpiresAt,
+		}
+	}
+	return dto.NewMedia(media, url, expiresAt, variants), nil
+}
+
+// readMediaContent returns the content that is stored, the images are read in memory in order to remove
+// their metadata (like the location in the exif) and the rest of the files are streamed as they are
+func readMediaContent(file multipart.File, contentType string, size int64) (io.Reader, int64, error) {
+	if !imaging.IsImage(contentType) {
+		return file, size, nil
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	stripped, err := imaging.StripMetadata(contentType, data)
+	if err != nil {
+		return nil, 0, apierror.Validation(apierror.FieldError{Field: MEDIA_FORM_FIELD, Message: "it is not a valid image"}).WithCause(err)
+	}
+	return bytes.NewReader(stripped), int64(len(stripped)), nil
+}
+
+// checkPostMedia validates the media sent with a new post, they must be of the user and not attached to another post
+func checkPostMedia(r *http.Request, mediaIds []string) []apierror.FieldError {
+	if len(mediaIds) > MAX_POST_MEDIA {
+		return []apierror.FieldError{{Field: "media_ids", Message: fmt.Sprintf("it can't have more than %d media", MAX_POST_MEDIA)}}
+	}
+
+	seen := map[string]bool{}
+	for _, id := range mediaIds {
+		media, err := repository.GetMediaById(r.Context(), id)
+		if err != nil || media.UserId != requestctx.UserId(r.Context()) {
+			return []apierror.FieldError{{Field: "media_ids", Message: fmt.Sprintf("%q is not one of your uploads", id)}}
+		}
+		if media.PostId != nil || seen[id] {
+			return []apierror.FieldError{{Field: "media_ids", Message: fmt.Sprintf("%q is already attached to a post", id)}}
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// UploadMediaHandler receives a multipart form with the file in the "file" field. The media
+// can be attached afterwards to a new post with its id
+func UploadMediaHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maxSize := s.Config().MaxMediaSize
+		tooLarge := apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeTooLarge, fmt.Sprintf("The file can't have more than %d bytes", maxSize))
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize+MEDIA_FORM_OVERHEAD)
+		if err := r.ParseMultipartForm(MEDIA_FORM_MEMORY); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				apierror.Write(w, r, tooLarge.WithCause(err))
+				return
+			}
+			apierror.Write(w, r, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeMediaType, "The body must be multipart/form-data").WithCause(err))
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		file, header, err := r.FormFile(MEDIA_FORM_FIELD)
+		if err != nil {
+			apierror.Write(w, r, apierror.Validation(apierror.FieldError{Field: MEDIA_FORM_FIELD, Message: "it is required"}))
+			return
+		}
+		defer file.Close()
+
+		if header.Size > maxSize {
+			apierror.Write(w, r, tooLarge)
+			return
+		}
+		if header.Size == 0 {
+			apierror.Write(w, r, apierror.Validation(apierror.FieldError{Field: MEDIA_FORM_FIELD, Message: "it is empty"}))
+			return
+		}
+
+		contentType, err := detectMediaType(file)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		if contentType == "" {
+			apierror.Write(w, r, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeMediaType, "Only images (jpeg, png, gif, webp), pdf and plain text files can be uploaded"))
+			return
+		}
+
+		content, size, err := readMediaContent(file, contentType, header.Size)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		id, err := ksuid.NewRandom()
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// the variants of the images are generated once they are attached to a post
+		status := models.MediaStatusReady
+		if imaging.IsImage(contentType) {
+			status = models.MediaStatusPending
+		}
+
+		media := models.Media{
+			Id:          id.String(),
+			UserId:      requestctx.UserId(r.Context()),
+			StorageKey:  "media/" + id.String() + MediaTypes[contentType],
+			ContentType: contentType,
+			FileName:    mediaFileName(header.Filename),
+			Size:        size,
+			Status:      status,
+		}
+
+		if err := storage.Put(r.Context(), media.StorageKey, content, media.Size, media.ContentType); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := repository.InsertMedia(r.Context(), &media); err != nil {
+			// the file would be left without any reference to it
+			storage.Delete(r.Context(), media.StorageKey)
+			apierror.Write(w, r, err)
+			return
+		}
+
+		response, err := newMediaResponse(&media)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// GetMediaHandler returns the media with a new signed url, the media that are not attached
+// can only be seen by their owner and the rest by the users that can see their post
+func GetMediaHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := requestctx.UserId(r.Context())
+
+		media, err := repository.GetMediaById(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if media.PostId == nil {
+			if media.UserId != userId {
+				apierror.Write(w, r, repository.ErrNotFound)
+				return
+			}
+		} else if _, err := repository.GetPostById(r.Context(), *media.PostId, userId); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		response, err := newMediaResponse(media)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
+}
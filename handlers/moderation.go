@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	MAX_MODERATION_NOTE_LENGTH = 500
+)
+
+type ModerationActionRequest struct {
+	// dismiss, hide or suspend
+	Action string `json:"action"`
+	Note   string `json:"note"`
+}
+
+// checkModerator only lets the moderators through, the role is read on every request
+// so it stops working as soon as it is removed
+func checkModerator(r *http.Request) error {
+	user, err := repository.GetUserById(r.Context(), requestctx.UserId(r.Context()))
+	if err != nil {
+		return err
+	}
+	if !user.IsModerator() {
+		return repository.ErrForbidden
+	}
+	return nil
+}
+
+// parseModerationQuery reads the cursor and the limit of the moderation listings
+func parseModerationQuery(r *http.Request) (*models.ModerationQuery, error) {
+	limit, err := parseLimit(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := parseCursor(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ModerationQuery{Cursor: cursor, Limit: limit}, nil
+}
+
+// ListReportedPostsHandler is the moderation queue, the posts with open reports and their counts by reason
+func ListReportedPostsHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkModerator(r); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		query, err := parseModerationQuery(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		limit := query.Limit
+
+		// ask for one extra post in order to know if there is a next page
+		query.Limit++
+		posts, err := repository.ListReportedPosts(r.Context(), query)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var nextCursor string
+		if uint64(len(posts)) > limit {
+			posts = posts[:limit]
+			last := posts[len(posts)-1]
+			nextCursor = (&models.Cursor{CreatedAt: last.LastReportedAt, Id: last.PostId}).Encode()
+		}
+
+		setNextLink(w, r, nextCursor, limit)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PageResponse{
+			Data:       dto.NewReportedPosts(posts),
+			NextCursor: nextCursor,
+		})
+	}
+}
+
+// ModeratePostHandler resolves the open reports of the post with the action, the moderator
+// and the time are recorded
+func ModeratePostHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkModerator(r); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var request = ModerationActionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			apierror.Write(w, r, apierror.InvalidJSON(err))
+			return
+		}
+
+		var fields []apierror.FieldError
+		if !models.IsModerationAction(request.Action) {
+			fields = append(fields, apierror.FieldError{Field: "action", Message: "it must be dismiss, hide or suspend"})
+		}
+		request.Note = strings.TrimSpace(request.Note)
+		if utf8.RuneCountInString(request.Note) > MAX_MODERATION_NOTE_LENGTH {
+			fields = append(fields, apierror.FieldError{Field: "note", Message: fmt.Sprintf("it can't have more than %d characters", MAX_MODERATION_NOTE_LENGTH)})
+		}
+		if len(fields) > 0 {
+			apierror.Write(w, r, apierror.Validation(fields...))
+			return
+		}
+
+		id, err := ksuid.NewRandom()
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		moderatorId := requestctx.UserId(r.Context())
+		action := models.ModerationAction{
+			Id:          id.String(),
+			PostId:      mux.Vars(r)["id"],
+			ModeratorId: &moderatorId,
+			Action:      models.ModerationActionKind(request.Action),
+			Note:        request.Note,
+		}
+
+		if err := repository.ModeratePost(r.Context(), &action); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(dto.NewModerationAction(&action))
+	}
+}
+
+// ListModerationActionsHandler returns the record of the moderation, including the automatic hides
+func ListModerationActionsHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := checkModerator(r); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		query, err := parseModerationQuery(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		limit := query.Limit
+
+		// ask for one extra action in order to know if there is a next page
+		query.Limit++
+		actions, err := repository.ListModerationActions(r.Context(), query)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var nextCursor string
+		if uint64(len(actions)) > limit {
+			actions = actions[:limit]
+			last := actions[len(actions)-1]
+			nextCursor = (&models.Cursor{CreatedAt: last.CreatedAt, Id: last.Id}).Encode()
+		}
+
+		setNextLink(w, r, nextCursor, limit)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PageResponse{
+			Data:       dto.NewModerationActions(actions),
+			NextCursor: nextCursor,
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+const (
+	DEFAULT_PAGE_SIZE = 20
+	MAX_PAGE_SIZE     = 100
+)
+
+// envelope used by every list endpoint
+type PageResponse struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// parseLimit reads the "limit" query param, if it is missing the default page size is used
+// and if it is bigger than the server cap it is reduced to MAX_PAGE_SIZE
+func parseLimit(r *http.Request) (uint64, error) {
+	limitStr := r.URL.Query().Get("limit")
+	if limitStr == "" {
+		return DEFAULT_PAGE_SIZE, nil
+	}
+
+	limit, err := strconv.ParseUint(limitStr, 10, 64)
+	if err != nil || limit == 0 {
+		return 0, apierror.InvalidParam("limit", "it must be a positive integer")
+	}
+
+	if limit > MAX_PAGE_SIZE {
+		limit = MAX_PAGE_SIZE
+	}
+	return limit, nil
+}
+
+// parseCursor reads the "cursor" query param, it is nil when the first page is requested
+func parseCursor(r *http.Request) (*models.Cursor, error) {
+	cursorStr := r.URL.Query().Get("cursor")
+	if cursorStr == "" {
+		return nil, nil
+	}
+
+	cursor, err := models.DecodeCursor(cursorStr)
+	if err != nil {
+		return nil, apierror.InvalidParam("cursor", "it must be a next_cursor returned by a previous page")
+	}
+	return cursor, nil
+}
+
+// setNextLink adds a "Link" header pointing to the next page, keeping the rest of the query params
+func setNextLink(w http.ResponseWriter, r *http.Request, nextCursor string, limit uint64) {
+	if nextCursor == "" {
+		return
+	}
+
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", nextCursor)
+	query.Set("limit", strconv.FormatUint(limit, 10))
+	next.RawQuery = query.Encode()
+
+	w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+}
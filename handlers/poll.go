@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/notify"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	MIN_POLL_OPTIONS       = 2
+	MAX_POLL_OPTIONS       = 6
+	MAX_POLL_OPTION_LENGTH = 100
+)
+
+type PollRequest struct {
+	Options  []string   `json:"options"`
+	Multiple bool       `json:"multiple"`
+	ClosesAt *time.Time `json:"closes_at"`
+}
+
+type VoteRequest struct {
+	OptionIds []string `json:"option_ids"`
+}
+
+// parsePoll validates the poll sent with a new post, it returns nil when there is no poll.
+// The poll can't close before the post is published
+func parsePoll(request *PollRequest, publishAt *time.Time, now time.Time) (*models.Poll, []apierror.FieldError) {
+	if request == nil {
+		return nil, nil
+	}
+
+	var fields []apierror.FieldError
+	if len(request.Options) < MIN_POLL_OPTIONS || len(request.Options) > MAX_POLL_OPTIONS {
+		fields = append(fields, apierror.FieldError{Field: "poll.options", Message: fmt.Sprintf("it must have between %d and %d options", MIN_POLL_OPTIONS, MAX_POLL_OPTIONS)})
+	}
+
+	poll := models.Poll{Multiple: request.Multiple, ClosesAt: request.ClosesAt}
+	seen := map[string]bool{}
+	for i, text := range request.Options {
+		text = strings.TrimSpace(text)
+		field := fmt.Sprintf("poll.options[%d]", i)
+		switch {
+		case text == "":
+			fields = append(fields, apierror.FieldError{Field: field, Message: "it is required"})
+		case utf8.RuneCountInString(text) > MAX_POLL_OPTION_LENGTH:
+			fields = append(fields, apierror.FieldError{Field: field, Message: fmt.Sprintf("it can't have more than %d characters", MAX_POLL_OPTION_LENGTH)})
+		case seen[strings.ToLower(text)]:
+			fields = append(fields, apierror.FieldError{Field: field, Message: "it is repeated"})
+		}
+		seen[strings.ToLower(text)] = true
+		poll.Options = append(poll.Options, models.PollOption{Id: ksuid.New().String(), Text: text})
+	}
+
+	if request.ClosesAt != nil {
+		opensAt := now
+		if publishAt != nil {
+			opensAt = *publishAt
+		}
+		if !request.ClosesAt.After(opensAt) {
+			fields = append(fields, apierror.FieldError{Field: "poll.closes_at", Message: "it must be after the publication of the post"})
+		}
+		// closes_at is a TIMESTAMP compared against NOW(), the offset of the client would be dropped
+		closesAt := request.ClosesAt.UTC()
+		poll.ClosesAt = &closesAt
+	}
+
+	return &poll, fields
+}
+
+// parseVote returns the options chosen by the user without repetitions, they must be of the poll
+func parseVote(r *http.Request, poll *models.Poll) ([]string, error) {
+	var request = VoteRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, apierror.InvalidJSON(err)
+	}
+
+	optionIds := []string{}
+	seen := map[string]bool{}
+	for _, id := range request.OptionIds {
+		if !poll.HasOption(id) {
+			return nil, apierror.Validation(apierror.FieldError{Field: "option_ids", Message: fmt.Sprintf("%q is not an option of the poll", id)})
+		}
+		if !seen[id] {
+			seen[id] = true
+			optionIds = append(optionIds, id)
+		}
+	}
+
+	switch {
+	case len(optionIds) == 0:
+		return nil, apierror.Validation(apierror.FieldError{Field: "option_ids", Message: "it is required"})
+	case !poll.Multiple && len(optionIds) > 1:
+		return nil, apierror.Validation(apierror.FieldError{Field: "option_ids", Message: "only one option can be chosen"})
+	}
+	return optionIds, nil
+}
+
+// getVotablePoll returns the poll of the post of the url, only the polls of the published posts
+// that the user can see and whose author didn't block the user can be voted
+func getVotablePoll(r *http.Request) (*models.Poll, error) {
+	postId := mux.Vars(r)["id"]
+	userId := requestctx.UserId(r.Context())
+
+	post, err := repository.GetPostById(r.Context(), postId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotBlocked(r, post.UserId); err != nil {
+		return nil, err
+	}
+
+	polls, err := repository.GetPolls(r.Context(), []string{postId}, userId)
+	if err != nil {
+		return nil, err
+	}
+	poll, ok := polls[postId]
+	if !ok {
+		return nil, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "The post has no poll")
+	}
+
+	if post.Status != models.StatusPublished {
+		return nil, apierror.New(http.StatusConflict, apierror.CodeConflict, "The poll can't be voted until the post is published")
+	}
+	if poll.IsClosed(time.Now()) {
+		return nil, apierror.New(http.StatusConflict, apierror.CodeConflict, "The poll is closed")
+	}
+	return poll, nil
+}
+
+// VoteHandler stores the vote of the user, it can't be changed afterwards.
+// The new tallies are sent to the subscribers of the post
+func VoteHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		postId := mux.Vars(r)["id"]
+		userId := requestctx.UserId(r.Context())
+
+		poll, err := getVotablePoll(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		optionIds, err := parseVote(r, poll)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		err = repository.Vote(r.Context(), postId, userId, optionIds)
+		if errors.Is(err, repository.ErrConflict) {
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "You already voted in this poll").WithCause(err))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		polls, err := repository.GetPolls(r.Context(), []string{postId}, userId)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		poll = polls[postId]
+
+		if err := notify.PollUpdated(r.Context(), s.Hub(), poll); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dto.NewPoll(poll))
+	}
+}
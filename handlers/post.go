@@ -2,12 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/extract"
+	"github.com/emavillamayorpsh/rest-ws/markdown"
 	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/notify"
 	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
 	"github.com/emavillamayorpsh/rest-ws/server"
 	"github.com/golang-jwt/jwt"
 	"github.com/gorilla/mux"
@@ -18,15 +27,171 @@ type UpsertPostRequest struct {
 	PostContent string `json:"post_content"`
 }
 
-type PostResponse struct {
-	Id string `json:"id"`
+// InsertPostRequest can create drafts, or posts scheduled to be published at publish_at.
+// The posts are public when the visibility is not sent
+type InsertPostRequest struct {
 	PostContent string `json:"post_content"`
+	Status string `json:"status"`
+	PublishAt *time.Time `json:"publish_at"`
+	Visibility string `json:"visibility"`
+	// optional, the poll can't be changed once the post is created
+	Poll *PollRequest `json:"poll"`
+	// ids of the media uploaded with POST /media, in the order they are shown
+	MediaIds []string `json:"media_ids"`
 }
 
+// newPostResponses loads the counters of all the posts at once, instead of one query per post,
+// and embeds the original of the reposts and quotes. The plain reposts without original are left out,
+// so there can be less responses than posts
+func newPostResponses(r *http.Request, posts []*models.Post) ([]dto.Post, error) {
+	responses, err := mapPosts(r, posts)
+	if err != nil {
+		return nil, err
+	}
+
+	var originalIds []string
+	for _, post := range posts {
+		if post.RepostOfId != nil {
+			originalIds = append(originalIds, *post.RepostOfId)
+		}
+	}
+	if len(originalIds) == 0 {
+		return responses, nil
+	}
+
+	// only one level is embedded, the originals that are quotes only have their repost_of_id
+	originals, err := repository.GetPostsByIds(r.Context(), originalIds, requestctx.UserId(r.Context()))
+	if err != nil {
+		return nil, err
+	}
+	originalResponses, err := mapPosts(r, originals)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := map[string]*dto.Post{}
+	for i := range originalResponses {
+		byId[originalResponses[i].Id] = &originalResponses[i]
+	}
+	// a plain repost of a post that the viewer can't see, or whose author the viewer blocked
+	// or muted, has nothing to show
+	visible := responses[:0]
+	for i, post := range posts {
+		if post.RepostOfId != nil {
+			responses[i].RepostOf = byId[*post.RepostOfId]
+			if post.IsRepost() && responses[i].RepostOf == nil {
+				continue
+			}
+		}
+		visible = append(visible, responses[i])
+	}
+	return visible, nil
+}
+
+// newPostResponse maps a single post, it returns ErrNotFound for a plain repost with nothing to show
+func newPostResponse(r *http.Request, post *models.Post) (*dto.Post, error) {
+	responses, err := newPostResponses(r, []*models.Post{post})
+	if err != nil {
+		return nil, err
+	}
+	if len(responses) == 0 {
+		return nil, repository.ErrNotFound
+	}
+	return &responses[0], nil
+}
+
+// mapPosts maps the posts with their counters
+func mapPosts(r *http.Request, posts []*models.Post) ([]dto.Post, error) {
+	ids := make([]string, len(posts))
+	for i, post := range posts {
+		ids[i] = post.Id
+	}
+
+	commentCounts, err := repository.CountComments(r.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	reactions, err := repository.GetReactionSummaries(r.Context(), ids, requestctx.UserId(r.Context()))
+	if err != nil {
+		return nil, err
+	}
+
+	reposts, err := repository.CountReposts(r.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	polls, err := repository.GetPolls(r.Context(), ids, requestctx.UserId(r.Context()))
+	if err != nil {
+		return nil, err
+	}
+
+	postMedia, err := repository.ListPostMedia(r.Context(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.Post, len(posts))
+	for i, post := range posts {
+		media := make([]dto.Media, len(postMedia[post.Id]))
+		for j, item := range postMedia[post.Id] {
+			if media[j], err = newMediaResponse(item); err != nil {
+				return nil, err
+			}
+		}
+
+		responses[i] = dto.NewPost(post, dto.PostDetails{
+			Comments: commentCounts[post.Id],
+			Reactions: reactions[post.Id],
+			Reposts: reposts[post.Id],
+			Poll: polls[post.Id],
+			Media: media,
+		})
+	}
+	return responses, nil
+}
+
+var visibilityFieldError = apierror.FieldError{Field: "visibility", Message: "it must be public, followers or private"}
+
 type PostUpdateResponse struct {
 	Message string `json:"message"`
 }
 
+// renderContent validates the markdown sent by the client and returns its sanitized HTML
+func renderContent(s server.Server, field string, content string) (string, error) {
+	if strings.TrimSpace(content) == "" {
+		return "", apierror.Validation(apierror.FieldError{Field: field, Message: "it is required"})
+	}
+	if utf8.RuneCountInString(content) > s.Config().MaxPostLength {
+		return "", apierror.Validation(apierror.FieldError{
+			Field: field,
+			Message: fmt.Sprintf("it can't have more than %d characters", s.Config().MaxPostLength),
+		})
+	}
+
+	return markdown.Render(content)
+}
+
+// postEntities finds the #tags and @mentions of the content, they are stored in the same transaction as
+// the post. The users mentioned for the first time are notified after it is committed when the post is
+// already published, the rest are notified when the post is published
+func postEntities(r *http.Request, authorId string, content string) (*models.PostEntities, error) {
+	usernames, err := repository.GetUserIdsByUsernames(r.Context(), extract.Mentions(content))
+	if err != nil {
+		return nil, err
+	}
+
+	userIds := []string{}
+	for _, userId := range usernames {
+		if userId != authorId {
+			userIds = append(userIds, userId)
+		}
+	}
+
+	return &models.PostEntities{Tags: extract.Tags(content), MentionIds: userIds}, nil
+}
+
 func InsertPostHandler(s server.Server) http.HandlerFunc {
 	return func (w http.ResponseWriter, r *http.Request) {
 		// GET THE TOKEN FROM AUTHORIZATION
@@ -39,43 +204,101 @@ func InsertPostHandler(s server.Server) http.HandlerFunc {
 
 		// IN CASE TOKEN INVALID RETURN ERROR
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.InvalidToken(err))
 			return
 		}
 
 		// DESTRUCTURES THE TOKEN IN ORDER TO GET THE KEY/VALUES OF IT
 		if claims, ok := token.Claims.(*models.AppClaims); ok && token.Valid{
-			var postRequest = UpsertPostRequest{}
+			var postRequest = InsertPostRequest{}
 			if err := json.NewDecoder(r.Body).Decode(&postRequest); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.InvalidJSON(err))
+				return
+			}
+
+			postHtml, err := renderContent(s, "post_content", postRequest.PostContent)
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+
+			schedule, fields := resolveSchedule(nil, scheduleRequest{
+				Status: postRequest.Status,
+				PublishAt: postRequest.PublishAt,
+				PublishAtSent: postRequest.PublishAt != nil,
+			}, time.Now())
+			visibility := models.VisibilityPublic
+			if postRequest.Visibility != "" {
+				visibility = models.PostVisibility(postRequest.Visibility)
+			}
+			if !models.IsPostVisibility(string(visibility)) {
+				fields = append(fields, visibilityFieldError)
+			}
+			poll, pollFields := parsePoll(postRequest.Poll, postRequest.PublishAt, time.Now())
+			fields = append(fields, pollFields...)
+			fields = append(fields, checkPostMedia(r, postRequest.MediaIds)...)
+			if len(fields) > 0 {
+				apierror.Write(w, r, apierror.Validation(fields...))
+				return
+			}
+
+			entities, err := postEntities(r, claims.UserId, postRequest.PostContent)
+			if err != nil {
+				apierror.Write(w, r, err)
 				return
 			}
 
 			id, err := ksuid.NewRandom()
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				apierror.Write(w, r, err)
 				return
 			}
 
 			post := models.Post{
 				Id: id.String(),
 				PostContent: postRequest.PostContent,
+				PostHtml: postHtml,
 				UserId: claims.UserId,
+				Status: schedule.Status,
+				PublishAt: schedule.PublishAt,
+				Visibility: visibility,
+			}
+
+			err = repository.InsertPost(r.Context(), &post, entities, &models.PostAttachments{MediaIds: postRequest.MediaIds, Poll: poll})
+			if errors.Is(err, repository.ErrConflict) {
+				apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "The media were attached to another post meanwhile").WithCause(err))
+				return
+			}
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
 			}
 
-			err = repository.InsertPost(r.Context(), &post)
+			// read it back in order to return the values generated by the db
+			created, err := repository.GetPostById(r.Context(), post.Id, requestctx.UserId(r.Context()))
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				apierror.Write(w, r, err)
 				return
 			}
 
+			response, err := newPostResponse(r, created)
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+
+			// NOTIFY EVERY CLIENT CONNECTED TO THE WEBSOCKET, DRAFTS AND SCHEDULED POSTS ARE NOTIFIED WHEN PUBLISHED
+			if created.Status == models.StatusPublished {
+				if err = notify.PostPublished(r.Context(), s.Hub(), created); err != nil {
+					apierror.Write(w, r, err)
+					return
+				}
+			}
+
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(PostResponse{
-				Id: post.Id,
-				PostContent: post.PostContent,
-			})
+			json.NewEncoder(w).Encode(*response)
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.InvalidToken(nil))
 		}
 
 	}
@@ -86,14 +309,32 @@ func GetPostByIdHandler(s server.Server) http.HandlerFunc {
 		// get id from request url
 		params := mux.Vars(r)
 
-		post, err := repository.GetPostById(r.Context(), params["id"])
+		post, err := repository.GetPostById(r.Context(), params["id"], requestctx.UserId(r.Context()))
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		response, err := newPostResponse(r, post)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// the client already has this version of the post
+		etag, err := postETag(*response)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, err)
+			return
+		}
+		setPostETag(w, etag)
+		if notModified(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(post)
+		json.NewEncoder(w).Encode(*response)
 	}
 }
 
@@ -110,7 +351,7 @@ func UpdatePostHandler(s server.Server) http.HandlerFunc {
 
 		// IN CASE TOKEN INVALID RETURN ERROR
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.InvalidToken(err))
 			return
 		}
 
@@ -118,28 +359,69 @@ func UpdatePostHandler(s server.Server) http.HandlerFunc {
 		if claims, ok := token.Claims.(*models.AppClaims); ok && token.Valid{
 			var postRequest = UpsertPostRequest{}
 			if err := json.NewDecoder(r.Body).Decode(&postRequest); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				apierror.Write(w, r, apierror.InvalidJSON(err))
+				return
+			}
+
+			postHtml, err := renderContent(s, "post_content", postRequest.PostContent)
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+
+			// ONLY UPDATE THE VERSION OF THE POST THE CLIENT HAS SEEN
+			revisions, err := expectedRevisions(s, r)
+			if err != nil {
+				apierror.Write(w, r, err)
 				return
 			}
 
 			post := models.Post{
 				Id: params["id"],
 				PostContent: postRequest.PostContent,
+				PostHtml: postHtml,
 				UserId: claims.UserId,
 			}
 
-			err = repository.UpdatePost(r.Context(), &post)
+			// THE TAGS AND MENTIONS REMOVED FROM THE CONTENT ARE REMOVED TOO
+			entities, err := postEntities(r, claims.UserId, postRequest.PostContent)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				apierror.Write(w, r, err)
 				return
 			}
 
+			err = repository.UpdatePost(r.Context(), &post, entities, revisions)
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+
+			if post.Status == models.StatusPublished {
+				if err = notify.Mentioned(r.Context(), s.Hub(), &post, entities.AddedMentionIds); err != nil {
+					apierror.Write(w, r, err)
+					return
+				}
+			}
+
+			// the post has the values after the update
+			response, err := newPostResponse(r, &post)
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+			etag, err := postETag(*response)
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+
+			setPostETag(w, etag)
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(PostUpdateResponse{
 				Message: "Post Updated",
 			})
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.InvalidToken(nil))
 		}
 
 	}
@@ -158,7 +440,7 @@ func DeletePostHandler(s server.Server) http.HandlerFunc {
 
 		// IN CASE TOKEN INVALID RETURN ERROR
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.InvalidToken(err))
 			return
 		}
 
@@ -166,7 +448,7 @@ func DeletePostHandler(s server.Server) http.HandlerFunc {
 		if claims, ok := token.Claims.(*models.AppClaims); ok && token.Valid{
 			err = repository.DeletePost(r.Context(), params["id"], claims.UserId)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				apierror.Write(w, r, err)
 				return
 			}
 
@@ -175,37 +457,230 @@ func DeletePostHandler(s server.Server) http.HandlerFunc {
 				Message: "Post Deleted",
 			})
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.InvalidToken(nil))
 		}
 
 	}
 }
 
+// parsePostQuery reads the filters, sort and page params of the posts listing
+func parsePostQuery(r *http.Request) (*models.PostQuery, error) {
+	var err error
+	params := r.URL.Query()
+	query := models.PostQuery{
+		ViewerId: requestctx.UserId(r.Context()),
+		UserId: params.Get("user_id"),
+		Contains: params.Get("contains"),
+		Sort: models.SortNewest,
+	}
+
+	if query.Limit, err = parseLimit(r); err != nil {
+		return nil, err
+	}
+
+	if createdAfter := params.Get("created_after"); createdAfter != "" {
+		t, err := time.Parse(time.RFC3339, createdAfter)
+		if err != nil {
+			return nil, apierror.InvalidParam("created_after", "it must be a RFC 3339 date")
+		}
+		// posts.created_at is a TIMESTAMP in UTC, the offset of the client would be dropped
+		t = t.UTC()
+		query.CreatedAfter = &t
+	}
+
+	if createdBefore := params.Get("created_before"); createdBefore != "" {
+		t, err := time.Parse(time.RFC3339, createdBefore)
+		if err != nil {
+			return nil, apierror.InvalidParam("created_before", "it must be a RFC 3339 date")
+		}
+		t = t.UTC()
+		query.CreatedBefore = &t
+	}
+
+	switch sort := models.PostSort(params.Get("sort")); sort {
+	case "":
+	case models.SortNewest, models.SortOldest:
+		query.Sort = sort
+	default:
+		return nil, apierror.InvalidParam("sort", fmt.Sprintf("it must be %q or %q", models.SortNewest, models.SortOldest))
+	}
+
+	if cursorStr := params.Get("cursor"); cursorStr != "" {
+		// validate that it is a cursor generated by us
+		if query.Cursor, err = models.DecodeCursor(cursorStr); err != nil {
+			return nil, apierror.InvalidParam("cursor", "it must be a next_cursor returned by a previous page")
+		}
+	}
+
+	return &query, nil
+}
+
+// writePostPage answers with a page of the posts matching the query
+func writePostPage(w http.ResponseWriter, r *http.Request, query *models.PostQuery) {
+	// ask for one extra post in order to know if there is a next page
+	limit := query.Limit
+	query.Limit++
+	posts, err := repository.ListPost(r.Context(), query)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	writePosts(w, r, posts, limit)
+}
+
+// writePosts answers with a page of posts, posts has one more post than limit when there is a next page
+func writePosts(w http.ResponseWriter, r *http.Request, posts []*models.Post, limit uint64) {
+	var nextCursor string
+	if uint64(len(posts)) > limit {
+		posts = posts[:limit]
+		last := posts[len(posts) - 1]
+		nextCursor = (&models.Cursor{CreatedAt: last.ListedAt(), Id: last.Id}).Encode()
+	}
+
+	responses, err := newPostResponses(r, posts)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	setNextLink(w, r, nextCursor, limit)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PageResponse{
+		Data: responses,
+		NextCursor: nextCursor,
+	})
+}
+
 func ListPostHandler(s server.Server) http.HandlerFunc {
 	return func (w http.ResponseWriter, r *http.Request) {
-		var err error
+		// get "query params" from url
+		query, err := parsePostQuery(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
 
-		// get "query param" from url
-		pageStr :=  r.URL.Query().Get("page")
-		var page = uint64(0)
+		writePostPage(w, r, query)
+	}
+}
 
-		// validate that it has a page number
-		if pageStr != "" {
-			page, err =  strconv.ParseUint(pageStr,10, 64)
-			// validate that it is a "valid" number
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+// ListTrashHandler lists the deleted posts of the current user, until they are purged
+func ListTrashHandler(s server.Server) http.HandlerFunc {
+	return func (w http.ResponseWriter, r *http.Request) {
+		query, err := parsePostQuery(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		query.UserId = requestctx.UserId(r.Context())
+		query.Deleted = true
+		writePostPage(w, r, query)
+	}
+}
+
+func RestorePostHandler(s server.Server) http.HandlerFunc {
+	return func (w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		if err := repository.RestorePost(r.Context(), params["id"], requestctx.UserId(r.Context())); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PostUpdateResponse{
+			Message: "Post Restored",
+		})
+	}
+}
+
+func ListTagPostsHandler(s server.Server) http.HandlerFunc {
+	return func (w http.ResponseWriter, r *http.Request) {
+		query, err := parsePostQuery(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// tags are stored in lowercase
+		query.Tag = strings.ToLower(strings.TrimPrefix(mux.Vars(r)["tag"], "#"))
+		writePostPage(w, r, query)
+	}
+}
+
+func SearchPostsHandler(s server.Server) http.HandlerFunc {
+	return func (w http.ResponseWriter, r *http.Request) {
+		q := strings.TrimSpace(r.URL.Query().Get("q"))
+		if q == "" {
+			apierror.Write(w, r, apierror.InvalidParam("q", "it is required"))
+			return
+		}
+
+		limit, err := parseLimit(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		query := models.PostSearchQuery{
+			ViewerId: requestctx.UserId(r.Context()),
+			Query: q,
+			// ask for one extra result in order to know if there is a next page
+			Limit: limit + 1,
+		}
+
+		if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+			if query.Cursor, err = models.DecodeSearchCursor(cursorStr); err != nil {
+				apierror.Write(w, r, apierror.InvalidParam("cursor", "it must be a next_cursor returned by a previous page"))
 				return
 			}
 		}
 
-		posts, err := repository.ListPost(r.Context(), page)
+		results, err := repository.SearchPosts(r.Context(), &query)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var nextCursor string
+		if uint64(len(results)) > limit {
+			results = results[:limit]
+			last := results[len(results) - 1]
+			nextCursor = (&models.SearchCursor{Rank: last.Rank, Id: last.Id}).Encode()
+		}
+
+		posts := make([]*models.Post, len(results))
+		for i, result := range results {
+			posts[i] = &result.Post
+		}
+		responses, err := newPostResponses(r, posts)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, err)
 			return
 		}
 
+		byId := map[string]dto.Post{}
+		for _, response := range responses {
+			byId[response.Id] = response
+		}
+		data := []dto.SearchResult{}
+		for _, result := range results {
+			if response, ok := byId[result.Id]; ok {
+				data = append(data, dto.SearchResult{
+					Post: response,
+					Rank: result.Rank,
+					Snippet: result.Snippet,
+				})
+			}
+		}
+
+		setNextLink(w, r, nextCursor, limit)
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(posts)
+		json.NewEncoder(w).Encode(PageResponse{
+			Data: data,
+			NextCursor: nextCursor,
+		})
 	}
-}
\ No newline at end of file
+}
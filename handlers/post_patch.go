@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/notify"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+)
+
+const (
+	MERGE_PATCH_CONTENT_TYPE = "application/merge-patch+json"
+)
+
+// fields of the post responses that the clients can't change
+var readOnlyPostFields = map[string]bool{
+	"id":            true,
+	"post_html":     true,
+	"created_at":    true,
+	"updated_at":    true,
+	"revision":      true,
+	"user_id":       true,
+	"deleted_at":    true,
+	"published_at":  true,
+	"comment_count": true,
+	"reactions":     true,
+}
+
+// requireMergePatch answers with 415 when the body is not a merge patch
+func requireMergePatch(w http.ResponseWriter, r *http.Request) bool {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != MERGE_PATCH_CONTENT_TYPE {
+		w.Header().Set("Accept-Patch", MERGE_PATCH_CONTENT_TYPE)
+		apierror.Write(w, r, apierror.New(http.StatusUnsupportedMediaType, apierror.CodeMediaType, "The body must be "+MERGE_PATCH_CONTENT_TYPE))
+		return false
+	}
+	return true
+}
+
+// parsePostPatch reads a JSON merge patch (RFC 7396), every field is validated and all
+// the invalid ones are returned together. The status and publish_at are returned apart
+// because they are validated against the current state of the post
+func parsePostPatch(s server.Server, r *http.Request) (*models.PostPatch, scheduleRequest, error) {
+	var document map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&document); err != nil || document == nil {
+		return nil, scheduleRequest{}, apierror.InvalidJSON(err)
+	}
+
+	keys := make([]string, 0, len(document))
+	for key := range document {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var patch = models.PostPatch{}
+	var schedule = scheduleRequest{}
+	var fields []apierror.FieldError
+
+	for _, key := range keys {
+		value := document[key]
+		switch {
+		case key == "post_content":
+			var content string
+			if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+				fields = append(fields, apierror.FieldError{Field: key, Message: "it can't be removed"})
+				continue
+			}
+			if err := json.Unmarshal(value, &content); err != nil {
+				fields = append(fields, apierror.FieldError{Field: key, Message: "it must be a string"})
+				continue
+			}
+
+			postHtml, err := renderContent(s, key, content)
+			var apiErr *apierror.Error
+			if errors.As(err, &apiErr) && len(apiErr.Fields) > 0 {
+				fields = append(fields, apiErr.Fields...)
+				continue
+			}
+			if err != nil {
+				return nil, schedule, err
+			}
+			patch.PostContent = &content
+			patch.PostHtml = &postHtml
+		case key == "status":
+			if err := json.Unmarshal(value, &schedule.Status); err != nil || schedule.Status == "" {
+				fields = append(fields, apierror.FieldError{Field: key, Message: "it must be draft, scheduled or published"})
+			}
+		case key == "publish_at":
+			// null removes the schedule
+			schedule.PublishAtSent = true
+			if err := json.Unmarshal(value, &schedule.PublishAt); err != nil {
+				fields = append(fields, apierror.FieldError{Field: key, Message: "it must be a RFC 3339 date or null"})
+			}
+		case key == "visibility":
+			var visibility string
+			if err := json.Unmarshal(value, &visibility); err != nil || !models.IsPostVisibility(visibility) {
+				fields = append(fields, visibilityFieldError)
+				continue
+			}
+			postVisibility := models.PostVisibility(visibility)
+			patch.Visibility = &postVisibility
+		case readOnlyPostFields[key]:
+			fields = append(fields, apierror.FieldError{Field: key, Message: "it is read only"})
+		default:
+			fields = append(fields, apierror.FieldError{Field: key, Message: "it is not a field of the posts"})
+		}
+	}
+
+	if len(fields) > 0 {
+		return nil, schedule, apierror.Validation(fields...)
+	}
+	return &patch, schedule, nil
+}
+
+// PatchPostHandler applies a merge patch to the post, only the fields sent are changed
+func PatchPostHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+		userId := requestctx.UserId(r.Context())
+
+		if !requireMergePatch(w, r) {
+			return
+		}
+
+		revisions, err := expectedRevisions(s, r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		patch, scheduleReq, err := parsePostPatch(s, r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		if patch.PostContent != nil {
+			if patch.Entities, err = postEntities(r, userId, *patch.PostContent); err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+		}
+
+		// the changes of status are validated against the current one
+		wasPublished := true
+		if !scheduleReq.isEmpty() {
+			current, err := repository.GetPostById(r.Context(), params["id"], userId)
+			if err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+
+			var fields []apierror.FieldError
+			if patch.Schedule, fields = resolveSchedule(current, scheduleReq, time.Now()); len(fields) > 0 {
+				apierror.Write(w, r, apierror.Validation(fields...))
+				return
+			}
+			wasPublished = current.Status == models.StatusPublished
+		}
+
+		var post *models.Post
+		if patch.IsEmpty() {
+			// nothing to change, a new revision is not created
+			post, err = repository.GetPostById(r.Context(), params["id"], userId)
+			if err == nil && post.UserId != userId {
+				err = repository.ErrForbidden
+			}
+			// If-Match is checked like in the updates, a stale version is rejected even without changes
+			if err == nil && !matchesRevision(revisions, post.Revision) {
+				err = repository.ErrPreconditionFailed
+			}
+		} else {
+			post, err = repository.PatchPost(r.Context(), params["id"], userId, patch, revisions)
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// the mentions of a post that was just published are notified with it
+		justPublished := !wasPublished && post.Status == models.StatusPublished
+		if patch.Entities != nil && post.Status == models.StatusPublished && !justPublished {
+			if err := notify.Mentioned(r.Context(), s.Hub(), post, patch.Entities.AddedMentionIds); err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+		}
+		if justPublished {
+			if err := notify.PostPublished(r.Context(), s.Hub(), post); err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+		}
+
+		response, err := newPostResponse(r, post)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		etag, err := postETag(*response)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		setPostETag(w, etag)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(*response)
+	}
+}
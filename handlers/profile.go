@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+)
+
+const (
+	MAX_DISPLAY_NAME_LENGTH = 50
+	MAX_BIO_LENGTH          = 160
+	MAX_AVATAR_URL_LENGTH   = 2048
+	MAX_USERNAME_LENGTH     = 30
+)
+
+// parseProfileText reads a string field of the profile, null clears it
+func parseProfileText(key string, value json.RawMessage, maxLength int) (string, *apierror.FieldError) {
+	if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+		return "", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		return "", &apierror.FieldError{Field: key, Message: "it must be a string or null"}
+	}
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) > maxLength {
+		return "", &apierror.FieldError{Field: key, Message: fmt.Sprintf("it can't have more than %d characters", maxLength)}
+	}
+	return text, nil
+}
+
+// parseUserPatch reads a JSON merge patch (RFC 7396) of the profile, all the invalid fields are returned together
+func parseUserPatch(r *http.Request) (*models.UserPatch, error) {
+	var document map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&document); err != nil || document == nil {
+		return nil, apierror.InvalidJSON(err)
+	}
+
+	keys := make([]string, 0, len(document))
+	for key := range document {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var patch = models.UserPatch{}
+	var fields []apierror.FieldError
+
+	for _, key := range keys {
+		var maxLength int
+		var target **string
+		switch key {
+		case "username":
+			maxLength, target = MAX_USERNAME_LENGTH, &patch.Username
+		case "display_name":
+			maxLength, target = MAX_DISPLAY_NAME_LENGTH, &patch.DisplayName
+		case "bio":
+			maxLength, target = MAX_BIO_LENGTH, &patch.Bio
+		case "avatar_url":
+			maxLength, target = MAX_AVATAR_URL_LENGTH, &patch.AvatarUrl
+		case "id", "email", "created_at":
+			fields = append(fields, apierror.FieldError{Field: key, Message: "it is read only"})
+			continue
+		default:
+			fields = append(fields, apierror.FieldError{Field: key, Message: "it is not a field of the profile"})
+			continue
+		}
+
+		text, fieldErr := parseProfileText(key, document[key], maxLength)
+		if fieldErr != nil {
+			fields = append(fields, *fieldErr)
+			continue
+		}
+
+		switch {
+		case key == "username" && text != "" && !validUsername.MatchString(text):
+			fields = append(fields, apierror.FieldError{Field: key, Message: "it must have between 3 and 30 letters, numbers or underscores"})
+			continue
+		case key == "avatar_url" && text != "" && !isHttpUrl(text):
+			fields = append(fields, apierror.FieldError{Field: key, Message: "it must be a http or https url"})
+			continue
+		}
+		*target = &text
+	}
+
+	if len(fields) > 0 {
+		return nil, apierror.Validation(fields...)
+	}
+	return &patch, nil
+}
+
+func isHttpUrl(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// PatchMeHandler changes the profile of the current user with a merge patch
+func PatchMeHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireMergePatch(w, r) {
+			return
+		}
+
+		patch, err := parseUserPatch(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		user, err := repository.UpdateUser(r.Context(), requestctx.UserId(r.Context()), patch)
+		if errors.Is(err, repository.ErrConflict) {
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "The username is already registered").WithCause(err))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dto.NewMe(user))
+	}
+}
+
+// GetUserHandler returns the public profile of any user
+func GetUserHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		user, err := repository.GetUserById(r.Context(), params["id"])
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		stats, err := repository.GetUserStats(r.Context(), user.Id)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dto.NewProfile(user, stats))
+	}
+}
+
+// ListUserPostsHandler lists the posts of an user that the current user can see, with the same params as the posts listing
+func ListUserPostsHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		query, err := parsePostQuery(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if _, err := repository.GetUserById(r.Context(), params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		query.UserId = params["id"]
+		writePostPage(w, r, query)
+	}
+}
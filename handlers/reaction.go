@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+)
+
+// validateReaction checks the kind of the url, that the post exists and that its author didn't block the user
+func validateReaction(r *http.Request) error {
+	params := mux.Vars(r)
+	if !models.IsReactionKind(params["kind"]) {
+		kinds := make([]string, 0, len(models.ReactionKinds))
+		for kind := range models.ReactionKinds {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		return apierror.InvalidParam("kind", "it must be one of "+strings.Join(kinds, ", "))
+	}
+
+	post, err := repository.GetPostById(r.Context(), params["id"], requestctx.UserId(r.Context()))
+	if err != nil {
+		return err
+	}
+	return checkNotBlocked(r, post.UserId)
+}
+
+// writeReactionSummary answers with the reactions of the post after the change
+func writeReactionSummary(w http.ResponseWriter, r *http.Request, postId string) {
+	summaries, err := repository.GetReactionSummaries(r.Context(), []string{postId}, requestctx.UserId(r.Context()))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewReactionSummary(summaries[postId]))
+}
+
+func AddReactionHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		if err := validateReaction(r); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := repository.AddReaction(r.Context(), params["id"], requestctx.UserId(r.Context()), params["kind"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		writeReactionSummary(w, r, params["id"])
+	}
+}
+
+func RemoveReactionHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		if err := validateReaction(r); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := repository.RemoveReaction(r.Context(), params["id"], requestctx.UserId(r.Context()), params["kind"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		writeReactionSummary(w, r, params["id"])
+	}
+}
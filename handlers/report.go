@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	MAX_REPORT_DETAILS_LENGTH = 500
+)
+
+type ReportRequest struct {
+	Reason string `json:"reason"`
+	// optional, what the moderators should know about the report
+	Details string `json:"details"`
+}
+
+// parseReportRequest validates the reason and the details, the details are trimmed
+func parseReportRequest(r *http.Request) (*ReportRequest, error) {
+	var request = ReportRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, apierror.InvalidJSON(err)
+	}
+
+	var fields []apierror.FieldError
+	if !models.IsReportReason(request.Reason) {
+		fields = append(fields, apierror.FieldError{Field: "reason", Message: "it must be one of " + strings.Join(models.ReportReasons, ", ")})
+	}
+	request.Details = strings.TrimSpace(request.Details)
+	if utf8.RuneCountInString(request.Details) > MAX_REPORT_DETAILS_LENGTH {
+		fields = append(fields, apierror.FieldError{Field: "details", Message: fmt.Sprintf("it can't have more than %d characters", MAX_REPORT_DETAILS_LENGTH)})
+	}
+	if len(fields) > 0 {
+		return nil, apierror.Validation(fields...)
+	}
+	return &request, nil
+}
+
+// ReportPostHandler flags a post for the moderators, the post is hidden when it gets
+// too many open reports. Every user can report a post once
+func ReportPostHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := requestctx.UserId(r.Context())
+
+		request, err := parseReportRequest(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		post, err := repository.GetPostById(r.Context(), mux.Vars(r)["id"], userId)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		if post.UserId == userId {
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "You can't report your own posts"))
+			return
+		}
+
+		id, err := ksuid.NewRandom()
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		report := models.Report{
+			Id:         id.String(),
+			PostId:     post.Id,
+			ReporterId: userId,
+			Reason:     request.Reason,
+			Details:    request.Details,
+		}
+
+		err = repository.InsertReport(r.Context(), &report, s.Config().AutoHideThreshold)
+		if errors.Is(err, repository.ErrConflict) {
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "You already reported this post").WithCause(err))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(dto.NewReport(&report))
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/notify"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+	"github.com/segmentio/ksuid"
+)
+
+// RepostRequest is optional, without post_content it is a plain repost, with it a quote
+type RepostRequest struct {
+	PostContent string `json:"post_content"`
+	Visibility  string `json:"visibility"`
+}
+
+// getRepostable returns the post that is reposted, the reposts of a repost point to its original.
+// Only the published public posts can be reposted
+func getRepostable(r *http.Request, id string) (*models.Post, error) {
+	userId := requestctx.UserId(r.Context())
+
+	post, err := repository.GetPostById(r.Context(), id, userId)
+	if err != nil {
+		return nil, err
+	}
+	if post.IsRepost() {
+		if post, err = repository.GetPostById(r.Context(), *post.RepostOfId, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	if post.Status != models.StatusPublished || post.Visibility != models.VisibilityPublic {
+		return nil, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Only the published public posts can be reposted")
+	}
+	if err := checkNotBlocked(r, post.UserId); err != nil {
+		return nil, err
+	}
+	return post, nil
+}
+
+func RepostHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request = RepostRequest{}
+		// the body can be empty for the plain reposts
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+			apierror.Write(w, r, apierror.InvalidJSON(err))
+			return
+		}
+
+		original, err := getRepostable(r, mux.Vars(r)["id"])
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		visibility := models.VisibilityPublic
+		if request.Visibility != "" {
+			visibility = models.PostVisibility(request.Visibility)
+		}
+		if !models.IsPostVisibility(string(visibility)) {
+			apierror.Write(w, r, apierror.Validation(visibilityFieldError))
+			return
+		}
+
+		id, err := ksuid.NewRandom()
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		post := models.Post{
+			Id:          id.String(),
+			PostContent: request.PostContent,
+			UserId:      requestctx.UserId(r.Context()),
+			Status:      models.StatusPublished,
+			Visibility:  visibility,
+			RepostOfId:  &original.Id,
+		}
+		if request.PostContent != "" {
+			if post.PostHtml, err = renderContent(s, "post_content", request.PostContent); err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+		}
+
+		entities, err := postEntities(r, post.UserId, request.PostContent)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		err = repository.InsertPost(r.Context(), &post, entities, nil)
+		if errors.Is(err, repository.ErrConflict) {
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "You already reposted this post").WithCause(err))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// read it back in order to return the values generated by the db
+		created, err := repository.GetPostById(r.Context(), post.Id, post.UserId)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		response, err := newPostResponse(r, created)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err = notify.PostPublished(r.Context(), s.Hub(), created); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		if err = notify.Reposted(r.Context(), s.Hub(), created, original); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(*response)
+	}
+}
+
+// UndoRepostHandler removes the plain repost of the post, the quotes are deleted as any other post
+func UndoRepostHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := repository.DeleteRepost(r.Context(), mux.Vars(r)["id"], requestctx.UserId(r.Context())); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
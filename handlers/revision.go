@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/diff"
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/gorilla/mux"
+)
+
+type RevisionDiffResponse struct {
+	PostId string      `json:"post_id"`
+	From   int         `json:"from"`
+	To     int         `json:"to"`
+	Lines  []diff.Line `json:"lines"`
+}
+
+// parseRevision reads a revision number from the query params
+func parseRevision(r *http.Request, param string) (int, error) {
+	revision, err := strconv.Atoi(r.URL.Query().Get(param))
+	if err != nil || revision < 1 {
+		return 0, apierror.InvalidParam(param, "it must be a revision number")
+	}
+	return revision, nil
+}
+
+// checkRevisionsReader only lets the author and the moderators read the revisions,
+// they keep the content that the author edited out
+func checkRevisionsReader(r *http.Request, postId string) error {
+	post, err := repository.GetPostById(r.Context(), postId, requestctx.UserId(r.Context()))
+	if err != nil {
+		return err
+	}
+	if post.UserId == requestctx.UserId(r.Context()) {
+		return nil
+	}
+	return checkModerator(r)
+}
+
+func ListPostRevisionsHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		limit, err := parseLimit(r)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// the cursor is the last revision of the previous page
+		before := 0
+		if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+			if before, err = parseRevision(r, "cursor"); err != nil {
+				apierror.Write(w, r, err)
+				return
+			}
+		}
+
+		if err := checkRevisionsReader(r, params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		// ask for one extra revision in order to know if there is a next page
+		revisions, err := repository.ListPostRevisions(r.Context(), params["id"], before, limit+1)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		var nextCursor string
+		if uint64(len(revisions)) > limit {
+			revisions = revisions[:limit]
+			nextCursor = strconv.Itoa(revisions[len(revisions)-1].Revision)
+		}
+
+		setNextLink(w, r, nextCursor, limit)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(PageResponse{
+			Data:       dto.NewPostRevisions(revisions),
+			NextCursor: nextCursor,
+		})
+	}
+}
+
+func GetPostRevisionHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		revision, err := strconv.Atoi(params["revision"])
+		if err != nil {
+			apierror.Write(w, r, apierror.InvalidParam("revision", "it must be a revision number"))
+			return
+		}
+
+		if err := checkRevisionsReader(r, params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		postRevision, err := repository.GetPostRevision(r.Context(), params["id"], revision)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(dto.NewPostRevision(postRevision))
+	}
+}
+
+// DiffPostRevisionsHandler compares the markdown of two revisions, line by line
+func DiffPostRevisionsHandler(s server.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		from, err := parseRevision(r, "from")
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		to, err := parseRevision(r, "to")
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		if err := checkRevisionsReader(r, params["id"]); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		fromRevision, err := repository.GetPostRevision(r.Context(), params["id"], from)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		toRevision, err := repository.GetPostRevision(r.Context(), params["id"], to)
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(RevisionDiffResponse{
+			PostId: params["id"],
+			From:   from,
+			To:     to,
+			Lines:  diff.Lines(fromRevision.PostContent, toRevision.PostContent),
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/models"
+)
+
+// scheduleRequest has the status and publish_at sent by the client
+type scheduleRequest struct {
+	Status        string
+	PublishAt     *time.Time
+	PublishAtSent bool
+}
+
+func (req scheduleRequest) isEmpty() bool {
+	return req.Status == "" && !req.PublishAtSent
+}
+
+// resolveSchedule returns the publication state of the post after the request, current is nil
+// for the new posts. It returns nil when the state of an existing post doesn't change
+func resolveSchedule(current *models.Post, req scheduleRequest, now time.Time) (*models.PostSchedule, []apierror.FieldError) {
+	if req.Status != "" && !models.IsPostStatus(req.Status) {
+		return nil, []apierror.FieldError{{Field: "status", Message: "it must be draft, scheduled or published"}}
+	}
+
+	if current != nil && current.Status == models.StatusPublished {
+		if (req.Status != "" && models.PostStatus(req.Status) != models.StatusPublished) || req.PublishAt != nil {
+			return nil, []apierror.FieldError{{Field: "status", Message: "the post is already published"}}
+		}
+		return nil, nil
+	}
+
+	status := models.PostStatus(req.Status)
+	publishAt := req.PublishAt
+	if current != nil && !req.PublishAtSent {
+		publishAt = current.PublishAt
+	}
+
+	if status == "" {
+		switch {
+		case req.PublishAt != nil:
+			status = models.StatusScheduled
+		case current == nil:
+			status = models.StatusPublished
+		case req.PublishAtSent:
+			// the schedule was removed, the post stays as a draft
+			status = models.StatusDraft
+		default:
+			return nil, nil
+		}
+	}
+
+	switch status {
+	case models.StatusPublished:
+		if req.PublishAt != nil {
+			return nil, []apierror.FieldError{{Field: "publish_at", Message: "it can't be used to publish the post now"}}
+		}
+		publishAt = nil
+	case models.StatusScheduled:
+		if publishAt == nil || !publishAt.After(now) {
+			return nil, []apierror.FieldError{{Field: "publish_at", Message: "it must be a date in the future"}}
+		}
+		// publish_at is a TIMESTAMP compared against NOW(), the offset of the client would be dropped
+		utc := publishAt.UTC()
+		publishAt = &utc
+	}
+
+	return &models.PostSchedule{Status: status, PublishAt: publishAt}, nil
+}
@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+	"github.com/emavillamayorpsh/rest-ws/dto"
 	"github.com/emavillamayorpsh/rest-ws/models"
 	"github.com/emavillamayorpsh/rest-ws/repository"
 	"github.com/emavillamayorpsh/rest-ws/server"
@@ -21,11 +25,25 @@ const (
 type SignUpLoginRequest struct {
 	Email string `json:"email"`
 	Password string `json:"password"`
+	// only used in the sign up, it is optional
+	Username string `json:"username"`
+}
+
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+// validateSignUp returns the fields of the request that can't be used to create an user
+func validateSignUp(request SignUpLoginRequest) []apierror.FieldError {
+	var fields []apierror.FieldError
+	if request.Username != "" && !validUsername.MatchString(request.Username) {
+		fields = append(fields, apierror.FieldError{Field: "username", Message: "it must have between 3 and 30 letters, numbers or underscores"})
+	}
+	return fields
 }
 
 type SignUpResponse struct {
 	Id string `json:"id"`
 	Email string `json:"email"`
+	Username string `json:"username"`
 }
 
 type LoginResponse struct {
@@ -38,18 +56,24 @@ func SignUpHandler(s server.Server) http.HandlerFunc {
 
 		err := json.NewDecoder(r.Body).Decode(&request)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apierror.Write(w, r, apierror.InvalidJSON(err))
+			return
+		}
+
+		if fields := validateSignUp(request); len(fields) > 0 {
+			apierror.Write(w, r, apierror.Validation(fields...))
 			return
 		}
 
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), HASH_COST)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, err)
+			return
 		}
 
 		id, err := ksuid.NewRandom()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, err)
 			return
 		}
 
@@ -57,11 +81,16 @@ func SignUpHandler(s server.Server) http.HandlerFunc {
 			Email: request.Email,
 			Password: string(hashedPassword),
 			Id: id.String(),
+			Username: request.Username,
 		}
 
 		err = repository.InsertUser(r.Context(), &user)
+		if errors.Is(err, repository.ErrConflict) {
+			apierror.Write(w, r, apierror.New(http.StatusConflict, apierror.CodeConflict, "The email or the username is already registered").WithCause(err))
+			return
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, err)
 			return
 		}
 
@@ -69,6 +98,7 @@ func SignUpHandler(s server.Server) http.HandlerFunc {
 		json.NewEncoder(w).Encode(SignUpResponse{
 			Id: user.Id,
 			Email: user.Email,
+			Username: user.Username,
 		})
 	}
 }
@@ -79,23 +109,29 @@ func LoginHandler(s server.Server) http.HandlerFunc {
 
 		err := json.NewDecoder(r.Body).Decode(&request)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apierror.Write(w, r, apierror.InvalidJSON(err))
 			return
 		}
 
 		user, err := repository.GetUserByEmail(r.Context(), request.Email)
+		// INVALID USER EMAIL DOESN'T EXIST
+		if errors.Is(err, repository.ErrNotFound) {
+			apierror.Write(w, r, apierror.Unauthorized("Invalid credentials"))
+			return
+		}
 		// ERROR IN REPOSITORY
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apierror.Write(w, r, err)
 			return
 		}
-		// INVALID USER EMAIL DOESN'T EXIST
-		if user == nil {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
-		}
 		// INVALID PASSWORD
 		if err:= bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)); err != nil {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.Unauthorized("Invalid credentials"))
+			return
+		}
+		// SUSPENDED BY A MODERATOR
+		if user.SuspendedAt != nil {
+			apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Your account is suspended"))
 			return
 		}
 
@@ -111,7 +147,7 @@ func LoginHandler(s server.Server) http.HandlerFunc {
 		// CONVERT TOKEN TO STRING
 		tokenString, err := token.SignedString([]byte(s.Config().JWTSecret))
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apierror.Write(w, r, err)
 			return
 		}
 
@@ -134,7 +170,7 @@ func MeHandler(s server.Server) http.HandlerFunc{
 
 		// IN CASE TOKEN INVALID RETURN ERROR
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusUnauthorized)
+			apierror.Write(w, r, apierror.InvalidToken(err))
 			return
 		}
 
@@ -143,14 +179,14 @@ func MeHandler(s server.Server) http.HandlerFunc{
 			// WITH THE USER ID EXTRACTED FROM THE TOKEN , GET THE USER IN THE DB
 			user, err := repository.GetUserById(r.Context(), claims.UserId)
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				apierror.Write(w, r, err)
 				return
 			}
 
 			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(user)
+			json.NewEncoder(w).Encode(dto.NewMe(user))
 		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.InvalidToken(nil))
 		}
 	}
 }
\ No newline at end of file
@@ -0,0 +1,243 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// The metadata is removed without decoding the images, so the originals keep their quality.
+// Only the orientation of the jpeg files is kept, without it they would be shown rotated
+
+var (
+	errInvalidJpeg = errors.New("invalid jpeg")
+	errInvalidPng  = errors.New("invalid png")
+	errInvalidWebp = errors.New("invalid webp")
+
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	exifHeader   = []byte("Exif\x00\x00")
+
+	// chunks with text, dates or exif that the users may not know they are sharing
+	pngMetadataChunks = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+)
+
+const (
+	JPEG_MARKER_SOI  = 0xD8
+	JPEG_MARKER_EOI  = 0xD9
+	JPEG_MARKER_SOS  = 0xDA
+	JPEG_MARKER_APP1 = 0xE1
+	// photoshop, it has the IPTC data
+	JPEG_MARKER_APP13 = 0xED
+	JPEG_MARKER_COM   = 0xFE
+
+	EXIF_TAG_ORIENTATION = 0x0112
+
+	WEBP_FLAG_EXIF = 0x08
+	WEBP_FLAG_XMP  = 0x04
+)
+
+// StripMetadata removes the exif, xmp and text metadata of the jpeg, png and webp images,
+// the rest of the types are returned as they are
+func StripMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJpeg(data)
+	case "image/png":
+		return stripPng(data)
+	case "image/webp":
+		return stripWebp(data)
+	}
+	return data, nil
+}
+
+func stripJpeg(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != JPEG_MARKER_SOI {
+		return nil, errInvalidJpeg
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+	orientationWritten := false
+
+	for i := 2; i < len(data); {
+		if data[i] != 0xFF {
+			return nil, errInvalidJpeg
+		}
+		// fill bytes before the marker
+		if i+1 < len(data) && data[i+1] == 0xFF {
+			i++
+			continue
+		}
+		if i+3 >= len(data) {
+			return nil, errInvalidJpeg
+		}
+
+		marker := data[i+1]
+		if marker == JPEG_MARKER_EOI || (marker >= 0xD0 && marker <= 0xD7) || marker == 0x01 {
+			out.Write(data[i : i+2])
+			i += 2
+			continue
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) || end < i+4 {
+			return nil, errInvalidJpeg
+		}
+		segment := data[i:end]
+
+		switch {
+		case marker == JPEG_MARKER_SOS:
+			// the compressed data goes until the end, there is no metadata after it
+			out.Write(data[i:])
+			return out.Bytes(), nil
+		case marker == JPEG_MARKER_APP1 && bytes.HasPrefix(segment[4:], exifHeader):
+			if orientation := exifOrientation(segment[4+len(exifHeader):]); orientation > 1 && !orientationWritten {
+				out.Write(orientationSegment(orientation))
+				orientationWritten = true
+			}
+		case marker == JPEG_MARKER_APP1, marker == JPEG_MARKER_APP13, marker == JPEG_MARKER_COM:
+		default:
+			out.Write(segment)
+		}
+		i = end
+	}
+	return nil, errInvalidJpeg
+}
+
+// exifOrientation reads the orientation tag of the first IFD of the exif data, it returns 1 (the normal one)
+// when it isn't there
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) || offset < 8 {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == EXIF_TAG_ORIENTATION {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
+
+// JpegOrientation returns the exif orientation of the jpeg image, 1 when it has none
+func JpegOrientation(data []byte) int {
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == JPEG_MARKER_SOS {
+			break
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) || end < i+4 {
+			break
+		}
+		if marker == JPEG_MARKER_APP1 && bytes.HasPrefix(data[i+4:end], exifHeader) {
+			return exifOrientation(data[i+4+len(exifHeader) : end])
+		}
+		i = end
+	}
+	return 1
+}
+
+// orientationSegment is an APP1 segment with an exif that only has the orientation
+func orientationSegment(orientation int) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	// a single entry: the orientation, a SHORT, with the value in the entry itself
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{EXIF_TAG_ORIENTATION, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{uint16(orientation), 0})
+	// no more IFDs
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	segment := []byte{0xFF, JPEG_MARKER_APP1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(exifHeader)+tiff.Len()))
+	segment = append(segment, exifHeader...)
+	return append(segment, tiff.Bytes()...)
+}
+
+func stripPng(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errInvalidPng
+	}
+
+	var out bytes.Buffer
+	out.Write(pngSignature)
+	for i := len(pngSignature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, errInvalidPng
+		}
+		// length, type, data and crc
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i+12 {
+			return nil, errInvalidPng
+		}
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
+
+func stripWebp(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errInvalidWebp
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errInvalidWebp
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		// the chunks are padded to an even size
+		end := i + 8 + size + size%2
+		if end > len(data) || size < 0 {
+			return nil, errInvalidWebp
+		}
+
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte{}, data[i:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= WEBP_FLAG_EXIF | WEBP_FLAG_XMP
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
@@ -0,0 +1,209 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// secret is in every metadata block of the test images, it must not be in the stripped ones
+const secret = "secret location"
+
+// testImage is a width x height image, red on its left half and blue on its right half
+func testImage(width int, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.Set(x, y, color.NRGBA{R: 255, A: 255})
+			} else {
+				img.Set(x, y, color.NRGBA{B: 255, A: 255})
+			}
+		}
+	}
+	return img
+}
+
+// exifSegment is a little endian APP1 exif with the orientation and a description, the data of the
+// description is after the IFD like the cameras write it
+func exifSegment(orientation int) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("II")
+	binary.Write(&tiff, binary.LittleEndian, uint16(42))
+	binary.Write(&tiff, binary.LittleEndian, uint32(8))
+	binary.Write(&tiff, binary.LittleEndian, uint16(2))
+	// ImageDescription, ASCII, its data at the offset after the IFD
+	binary.Write(&tiff, binary.LittleEndian, []uint16{0x010E, 2})
+	binary.Write(&tiff, binary.LittleEndian, []uint32{uint32(len(secret) + 1), 8 + 2 + 2*12 + 4})
+	binary.Write(&tiff, binary.LittleEndian, []uint16{EXIF_TAG_ORIENTATION, 3})
+	binary.Write(&tiff, binary.LittleEndian, uint32(1))
+	binary.Write(&tiff, binary.LittleEndian, []uint16{uint16(orientation), 0})
+	binary.Write(&tiff, binary.LittleEndian, uint32(0))
+	tiff.WriteString(secret + "\x00")
+
+	return jpegSegment(JPEG_MARKER_APP1, append(append([]byte{}, exifHeader...), tiff.Bytes()...))
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(payload)))
+	return append(segment, payload...)
+}
+
+// testJpeg encodes the image and adds the segments after the SOI marker
+func testJpeg(t *testing.T, img image.Image, segments ...[]byte) []byte {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	data := append([]byte{}, encoded.Bytes()[:2]...)
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	return append(data, encoded.Bytes()[2:]...)
+}
+
+func pngChunk(chunkType string, payload []byte) []byte {
+	chunk := make([]byte, 8, 12+len(payload))
+	binary.BigEndian.PutUint32(chunk, uint32(len(payload)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, payload...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func webpChunk(chunkType string, payload []byte) []byte {
+	chunk := make([]byte, 8, 9+len(payload))
+	copy(chunk, chunkType)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(payload)))
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func TestStripMetadataJpeg(t *testing.T) {
+	xmp := jpegSegment(JPEG_MARKER_APP1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>"+secret+"</x:xmpmeta>"))
+	comment := jpegSegment(JPEG_MARKER_COM, []byte(secret))
+	data := testJpeg(t, testImage(40, 20), exifSegment(6), xmp, comment)
+
+	stripped, err := StripMetadata("image/jpeg", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stripped, []byte(secret)) {
+		t.Error("the metadata is still in the image")
+	}
+	if bytes.Contains(stripped, []byte("http://ns.adobe.com/xap/1.0/")) {
+		t.Error("the xmp is still in the image")
+	}
+	if orientation := JpegOrientation(stripped); orientation != 6 {
+		t.Errorf("got the orientation %d, want 6", orientation)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != image.Pt(40, 20) {
+		t.Errorf("got the size %v, want 40x20", size)
+	}
+}
+
+func TestStripMetadataJpegWithoutOrientation(t *testing.T) {
+	data := testJpeg(t, testImage(40, 20), exifSegment(1))
+
+	stripped, err := StripMetadata("image/jpeg", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the normal orientation is not written again
+	if bytes.Contains(stripped, exifHeader) {
+		t.Error("the exif is still in the image")
+	}
+}
+
+func TestStripMetadataPng(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, testImage(40, 20)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the chunks are added after IHDR, the signature and IHDR take 33 bytes
+	header := encoded.Bytes()[:33]
+	data := append([]byte{}, header...)
+	data = append(data, pngChunk("tEXt", []byte("Comment\x00"+secret))...)
+	data = append(data, pngChunk("eXIf", exifSegment(6)[4+len(exifHeader):])...)
+	data = append(data, encoded.Bytes()[33:]...)
+
+	stripped, err := StripMetadata("image/png", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, encoded.Bytes()) {
+		t.Error("the stripped image is not the one without the metadata chunks")
+	}
+}
+
+func TestStripMetadataWebp(t *testing.T) {
+	// the pixels are not decoded, the content of the image chunk doesn't matter
+	vp8x := make([]byte, 10)
+	vp8x[0] = WEBP_FLAG_EXIF | WEBP_FLAG_XMP
+	pixels := webpChunk("VP8L", []byte("pixels"))
+
+	var body []byte
+	body = append(body, "WEBP"...)
+	body = append(body, webpChunk("VP8X", vp8x)...)
+	body = append(body, pixels...)
+	// an odd size, the chunk is padded
+	body = append(body, webpChunk("EXIF", []byte(secret))...)
+	body = append(body, webpChunk("XMP ", []byte("<x:xmpmeta>"+secret+"</x:xmpmeta>"))...)
+	data := append([]byte("RIFF\x00\x00\x00\x00"), body...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(body)))
+
+	stripped, err := StripMetadata("image/webp", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := append([]byte("RIFF\x00\x00\x00\x00WEBP"), webpChunk("VP8X", make([]byte, 10))...)
+	want = append(want, pixels...)
+	binary.LittleEndian.PutUint32(want[4:], uint32(len(want)-8))
+	if !bytes.Equal(stripped, want) {
+		t.Errorf("got %q, want %q", stripped, want)
+	}
+}
+
+func TestStripMetadataInvalid(t *testing.T) {
+	tests := []struct {
+		contentType string
+		data        []byte
+	}{
+		{"image/jpeg", []byte("not a jpeg")},
+		{"image/jpeg", []byte{0xFF, JPEG_MARKER_SOI, 0xFF, JPEG_MARKER_APP1, 0xFF, 0xFF}},
+		{"image/png", []byte("not a png")},
+		{"image/png", append(append([]byte{}, pngSignature...), 0, 0, 0xFF, 0xFF, 'I', 'D', 'A', 'T')},
+		{"image/webp", []byte("RIFF\x00\x00\x00\x00WAVE")},
+		{"image/webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8L\xFF\xFF\x00\x00")},
+	}
+
+	for _, test := range tests {
+		if _, err := StripMetadata(test.contentType, test.data); err == nil {
+			t.Errorf("StripMetadata(%s, %q) didn't fail", test.contentType, test.data)
+		}
+	}
+}
+
+func TestStripMetadataOtherTypes(t *testing.T) {
+	data := []byte(secret)
+	stripped, err := StripMetadata("text/plain", data)
+	if err != nil || !bytes.Equal(stripped, data) {
+		t.Errorf("got %q, %v, want the same content", stripped, err)
+	}
+}
@@ -0,0 +1,47 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/storage"
+)
+
+// Process generates the variants of the image of the media, stores them next to it
+// and marks the media as ready
+func Process(ctx context.Context, media *models.Media) error {
+	content, err := storage.Get(ctx, media.StorageKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(content)
+	content.Close()
+	if err != nil {
+		return err
+	}
+
+	generated, err := GenerateVariants(data)
+	if err != nil {
+		return err
+	}
+
+	variants := make([]models.MediaVariant, len(generated))
+	for i, variant := range generated {
+		variants[i] = models.MediaVariant{
+			Name:        variant.Name,
+			StorageKey:  "media/" + media.Id + "/" + variant.Name + variant.Extension,
+			ContentType: variant.ContentType,
+			Width:       variant.Width,
+			Height:      variant.Height,
+			Size:        int64(len(variant.Data)),
+		}
+		if err := storage.Put(ctx, variants[i].StorageKey, bytes.NewReader(variant.Data), variants[i].Size, variant.ContentType); err != nil {
+			return err
+		}
+	}
+
+	return repository.SaveMediaVariants(ctx, media.Id, variants)
+}
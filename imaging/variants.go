@@ -0,0 +1,168 @@
+// Package imaging has the processing of the uploaded images, in pure Go so the server
+// doesn't need any library installed
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	// bigger images are not decoded, they would take too much memory
+	MAX_IMAGE_PIXELS = 50_000_000
+	JPEG_QUALITY     = 85
+)
+
+// VariantSpec is a size the images are resized to, they fit in a square of MaxSize
+// keeping their proportions. The images are never enlarged
+type VariantSpec struct {
+	Name    string
+	MaxSize int
+}
+
+var Variants = []VariantSpec{
+	{Name: "thumbnail", MaxSize: 320},
+	{Name: "medium", MaxSize: 1280},
+}
+
+// IsImage tells if the variants can be generated for the type
+func IsImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+type Variant struct {
+	Name        string
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// GenerateVariants decodes the image and encodes every variant, the opaque ones as jpeg and the rest as png.
+// They are encoded from the pixels so they don't have any metadata, the orientation of the jpeg files
+// is applied to them. Only the first frame of the animated images is used
+func GenerateVariants(data []byte) ([]Variant, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > MAX_IMAGE_PIXELS {
+		return nil, fmt.Errorf("the image has more than %d pixels", MAX_IMAGE_PIXELS)
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if source.Bounds().Empty() {
+		return nil, errors.New("the image is empty")
+	}
+
+	orientation := 1
+	if bytes.HasPrefix(data, []byte{0xFF, JPEG_MARKER_SOI}) {
+		orientation = JpegOrientation(data)
+	}
+
+	variants := make([]Variant, 0, len(Variants))
+	for _, spec := range Variants {
+		resized := orient(resize(source, spec.MaxSize, orientation >= 5), orientation)
+
+		variant := Variant{Name: spec.Name, Width: resized.Bounds().Dx(), Height: resized.Bounds().Dy()}
+		var encoded bytes.Buffer
+		if resized.Opaque() {
+			variant.ContentType, variant.Extension = "image/jpeg", ".jpg"
+			err = jpeg.Encode(&encoded, resized, &jpeg.Options{Quality: JPEG_QUALITY})
+		} else {
+			variant.ContentType, variant.Extension = "image/png", ".png"
+			err = png.Encode(&encoded, resized)
+		}
+		if err != nil {
+			return nil, err
+		}
+		variant.Data = encoded.Bytes()
+		variants = append(variants, variant)
+	}
+	return variants, nil
+}
+
+// resize scales the image to fit in maxSize, transposed is true when the image is rotated
+// afterwards so its width becomes its height
+func resize(source image.Image, maxSize int, transposed bool) *image.NRGBA {
+	width, height := source.Bounds().Dx(), source.Bounds().Dy()
+	if transposed {
+		width, height = height, width
+	}
+
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			height, width = height*maxSize/width, maxSize
+		} else {
+			width, height = width*maxSize/height, maxSize
+		}
+	}
+	// the very thin images would end without one of their sides
+	if width == 0 {
+		width = 1
+	}
+	if height == 0 {
+		height = 1
+	}
+	if transposed {
+		width, height = height, width
+	}
+
+	resized := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), source, source.Bounds(), draw.Src, nil)
+	return resized
+}
+
+// orient applies the exif orientation to the pixels, the values 5 to 8 swap the width and the height
+func orient(img *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	outWidth, outHeight := width, height
+	if orientation >= 5 {
+		outWidth, outHeight = height, width
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, outWidth, outHeight))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = width-1-x, y
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dx, dy = x, height-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = height-1-y, x
+			case 7:
+				dx, dy = height-1-y, width-1-x
+			case 8:
+				dx, dy = y, width-1-x
+			}
+			copy(out.Pix[out.PixOffset(dx, dy):out.PixOffset(dx, dy)+4], img.Pix[img.PixOffset(x, y):img.PixOffset(x, y)+4])
+		}
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestGenerateVariantsSizes(t *testing.T) {
+	tests := []struct {
+		name        string
+		width       int
+		height      int
+		orientation int
+		// sizes of the thumbnail and the medium variants
+		want []image.Point
+	}{
+		{name: "landscape", width: 1600, height: 800, orientation: 1, want: []image.Point{{320, 160}, {1280, 640}}},
+		{name: "portrait", width: 300, height: 600, orientation: 1, want: []image.Point{{160, 320}, {300, 600}}},
+		{name: "rotated", width: 600, height: 300, orientation: 6, want: []image.Point{{160, 320}, {300, 600}}},
+		{name: "mirrored", width: 600, height: 300, orientation: 2, want: []image.Point{{320, 160}, {600, 300}}},
+		{name: "very thin", width: 1000, height: 2, orientation: 1, want: []image.Point{{320, 1}, {1000, 2}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := testJpeg(t, testImage(test.width, test.height), exifSegment(test.orientation))
+
+			variants, err := GenerateVariants(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(variants) != len(Variants) {
+				t.Fatalf("got %d variants, want %d", len(variants), len(Variants))
+			}
+
+			for i, variant := range variants {
+				if variant.Name != Variants[i].Name {
+					t.Errorf("got the variant %s, want %s", variant.Name, Variants[i].Name)
+				}
+				if variant.ContentType != "image/jpeg" || variant.Extension != ".jpg" {
+					t.Errorf("got %s %s for an opaque image, want a jpeg", variant.ContentType, variant.Extension)
+				}
+				if size := image.Pt(variant.Width, variant.Height); size != test.want[i] {
+					t.Errorf("got the %s of %v, want %v", variant.Name, size, test.want[i])
+				}
+
+				decoded, _, err := image.Decode(bytes.NewReader(variant.Data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if size := decoded.Bounds().Size(); size != test.want[i] {
+					t.Errorf("the %s is encoded with %v, want %v", variant.Name, size, test.want[i])
+				}
+				if bytes.Contains(variant.Data, []byte(secret)) {
+					t.Errorf("the %s has the metadata of the original", variant.Name)
+				}
+			}
+		})
+	}
+}
+
+// isRed tells if the pixel is closer to the red than to the blue of testImage, jpeg doesn't keep them exact
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > b
+}
+
+func TestGenerateVariantsOrientation(t *testing.T) {
+	tests := []struct {
+		orientation int
+		// where the red half of the image ends after applying the orientation
+		redAt  image.Point
+		blueAt image.Point
+	}{
+		// left to right as it is
+		{orientation: 1, redAt: image.Pt(10, 40), blueAt: image.Pt(150, 40)},
+		// mirrored horizontally
+		{orientation: 2, redAt: image.Pt(150, 40), blueAt: image.Pt(10, 40)},
+		// rotated 90 degrees clockwise, the left side ends at the top
+		{orientation: 6, redAt: image.Pt(40, 10), blueAt: image.Pt(40, 150)},
+		// rotated 90 degrees counterclockwise, the left side ends at the bottom
+		{orientation: 8, redAt: image.Pt(40, 150), blueAt: image.Pt(40, 10)},
+	}
+
+	for _, test := range tests {
+		data := testJpeg(t, testImage(160, 80), exifSegment(test.orientation))
+
+		variants, err := GenerateVariants(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, _, err := image.Decode(bytes.NewReader(variants[0].Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !isRed(decoded.At(test.redAt.X, test.redAt.Y)) || isRed(decoded.At(test.blueAt.X, test.blueAt.Y)) {
+			t.Errorf("the orientation %d is not applied", test.orientation)
+		}
+	}
+}
+
+func TestGenerateVariantsTransparent(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 400))
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatal(err)
+	}
+
+	variants, err := GenerateVariants(encoded.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if variants[0].ContentType != "image/png" || variants[0].Width != 320 || variants[0].Height != 320 {
+		t.Errorf("got a %s of %dx%d, want a png of 320x320", variants[0].ContentType, variants[0].Width, variants[0].Height)
+	}
+}
+
+func TestGenerateVariantsInvalid(t *testing.T) {
+	if _, err := GenerateVariants([]byte("not an image")); err == nil {
+		t.Error("GenerateVariants didn't fail")
+	}
+}
@@ -2,17 +2,25 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/emavillamayorpsh/rest-ws/handlers"
 	"github.com/emavillamayorpsh/rest-ws/middleware"
 	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/emavillamayorpsh/rest-ws/storage"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 )
 
+const (
+	DEFAULT_MEDIA_URL_TTL = time.Hour
+)
+
 func main() {
 	// go get file and loaded so that go can access to the values in the .env file
 	err := godotenv.Load(".env")
@@ -26,12 +34,45 @@ func main() {
 	JWT_SECRET := os.Getenv("JWT_SECRET")
 	DATABASE_URL := os.Getenv("DATABASE_URL")
 
+	// optional values, the server uses its defaults when they are missing
+	POST_MAX_LENGTH, err := optionalIntEnv("POST_MAX_LENGTH")
+	if err != nil {
+		log.Fatal(err)
+	}
+	TRASH_RETENTION, err := optionalDurationEnv("TRASH_RETENTION")
+	if err != nil {
+		log.Fatal(err)
+	}
+	MEDIA_MAX_SIZE, err := optionalIntEnv("MEDIA_MAX_SIZE")
+	if err != nil {
+		log.Fatal(err)
+	}
+	AUTO_HIDE_THRESHOLD, err := optionalIntEnv("MODERATION_AUTO_HIDE_THRESHOLD")
+	if err != nil {
+		log.Fatal(err)
+	}
+	UNATTACHED_MEDIA_TTL, err := optionalDurationEnv("MEDIA_UNATTACHED_TTL")
+	if err != nil {
+		log.Fatal(err)
+	}
+	STORAGE, err := newStorage(PORT, JWT_SECRET)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 
 	// create a new server
 	s, err := server.NewServer(context.Background(), &server.Config{
 		JWTSecret: JWT_SECRET,
 		Port: PORT,
 		DatabaseUrl: DATABASE_URL,
+		MaxPostLength: POST_MAX_LENGTH,
+		TrashRetention: TRASH_RETENTION,
+		RequireIfMatch: os.Getenv("REQUIRE_IF_MATCH") == "true",
+		Storage: STORAGE,
+		MaxMediaSize: int64(MEDIA_MAX_SIZE),
+		AutoHideThreshold: AUTO_HIDE_THRESHOLD,
+		UnattachedMediaTTL: UNATTACHED_MEDIA_TTL,
 	})
 
 	if err != nil {
@@ -41,18 +82,137 @@ func main() {
 	s.Start(BindRoutes)
 }
 
+// optionalIntEnv returns 0 when the variable is not defined
+func optionalIntEnv(name string) (int, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number: %w", name, err)
+	}
+	return number, nil
+}
+
+// optionalDurationEnv returns 0 when the variable is not defined, the values are like "720h"
+func optionalDurationEnv(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a duration: %w", name, err)
+	}
+	return duration, nil
+}
+
+// newStorage builds the storage of the media from MEDIA_STORAGE, "local" (the default) or "s3"
+func newStorage(port string, jwtSecret string) (storage.Storage, error) {
+	urlTTL, err := optionalDurationEnv("MEDIA_URL_TTL")
+	if err != nil {
+		return nil, err
+	}
+	if urlTTL == 0 {
+		urlTTL = DEFAULT_MEDIA_URL_TTL
+	}
+
+	switch os.Getenv("MEDIA_STORAGE") {
+	case "", "local":
+		dir := envOrDefault("MEDIA_DIR", "media")
+		baseUrl := envOrDefault("MEDIA_BASE_URL", "http://localhost"+port)
+		return storage.NewLocal(dir, baseUrl, envOrDefault("MEDIA_SIGNING_KEY", jwtSecret), urlTTL)
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint: os.Getenv("S3_ENDPOINT"),
+			Region: os.Getenv("S3_REGION"),
+			Bucket: os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+			UrlTTL: urlTTL,
+		})
+	default:
+		return nil, fmt.Errorf("MEDIA_STORAGE must be local or s3")
+	}
+}
+
+func envOrDefault(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
 func BindRoutes(s server.Server, r *mux.Router) {
-	// FOR EACH ROUTE WE WILL APPLY THIS MIDDLEWARE
+	// FOR EACH ROUTE WE WILL APPLY THESE MIDDLEWARES
+	r.Use(middleware.RequestIDMiddleware())
 	r.Use(middleware.CheckAuthMiddleware(s))
 
 
 	r.HandleFunc("/", handlers.HomeHandler(s)).Methods(http.MethodGet)
 	r.HandleFunc("/signup", handlers.SignUpHandler(s)).Methods(http.MethodPost)
+	// the local storage serves its own files, their urls are signed so they don't need the token
+	if local, ok := s.Config().Storage.(*storage.Local); ok {
+		r.PathPrefix(storage.LOCAL_URL_PREFIX).Handler(local).Methods(http.MethodGet, http.MethodHead)
+	}
+	r.HandleFunc("/media", handlers.UploadMediaHandler(s)).Methods(http.MethodPost)
+	r.HandleFunc("/media/{id}", handlers.GetMediaHandler(s)).Methods(http.MethodGet)
 	r.HandleFunc("/login", handlers.LoginHandler(s)).Methods(http.MethodPost)
 	r.HandleFunc("/me", handlers.MeHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/me", handlers.PatchMeHandler(s)).Methods(http.MethodPatch)
+	r.HandleFunc("/me/trash", handlers.ListTrashHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/me/blocks", handlers.ListBlockedUsersHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/me/mutes", handlers.ListMutedUsersHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/me/collections", handlers.InsertCollectionHandler(s)).Methods(http.MethodPost)
+	r.HandleFunc("/me/collections", handlers.ListCollectionsHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/me/collections/{id}", handlers.RenameCollectionHandler(s)).Methods(http.MethodPut)
+	r.HandleFunc("/me/collections/{id}", handlers.DeleteCollectionHandler(s)).Methods(http.MethodDelete)
+	r.HandleFunc("/me/collections/{id}/posts", handlers.ListBookmarksHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/me/collections/{id}/posts/{postId}", handlers.AddBookmarkHandler(s)).Methods(http.MethodPut)
+	r.HandleFunc("/me/collections/{id}/posts/{postId}", handlers.RemoveBookmarkHandler(s)).Methods(http.MethodDelete)
+	r.HandleFunc("/timeline", handlers.TimelineHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/users/{id}", handlers.GetUserHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/users/{id}/posts", handlers.ListUserPostsHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/users/{id}/follow", handlers.FollowHandler(s)).Methods(http.MethodPut)
+	r.HandleFunc("/users/{id}/follow", handlers.UnfollowHandler(s)).Methods(http.MethodDelete)
+	r.HandleFunc("/users/{id}/followers", handlers.ListFollowersHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/users/{id}/following", handlers.ListFollowingHandler(s)).Methods(http.MethodGet)
+	r.HandleFunc("/users/{id}/block", handlers.BlockHandler(s)).Methods(http.MethodPut)
+	r.HandleFunc("/users/{id}/block", handlers.UnblockHandler(s)).Methods(http.MethodDelete)
+	r.HandleFunc("/users/{id}/mute", handlers.MuteHandler(s)).Methods(http.MethodPut)
+	r.HandleFunc("/users/{id}/mute", handlers.UnmuteHandler(s)).Methods(http.MethodDelete)
 	r.HandleFunc("/posts", handlers.InsertPostHandler((s))).Methods(http.MethodPost)
+	// registered before "/posts/{id}" so "search" is not taken as an id
+	r.HandleFunc("/posts/search", handlers.SearchPostsHandler((s))).Methods(http.MethodGet)
 	r.HandleFunc("/posts/{id}", handlers.GetPostByIdHandler((s))).Methods(http.MethodGet)
 	r.HandleFunc("/posts/{id}", handlers.UpdatePostHandler((s))).Methods(http.MethodPut)
+	r.HandleFunc("/posts/{id}", handlers.PatchPostHandler((s))).Methods(http.MethodPatch)
 	r.HandleFunc("/posts/{id}", handlers.DeletePostHandler((s))).Methods(http.MethodDelete)
 	r.HandleFunc("/posts", handlers.ListPostHandler((s))).Methods(http.MethodGet)
+	r.HandleFunc("/posts/{id}/restore", handlers.RestorePostHandler((s))).Methods(http.MethodPost)
+	r.HandleFunc("/posts/{id}/comments", handlers.InsertCommentHandler((s))).Methods(http.MethodPost)
+	r.HandleFunc("/posts/{id}/comments", handlers.ListCommentsHandler((s))).Methods(http.MethodGet)
+	r.HandleFunc("/posts/{id}/comments/{commentId}", handlers.GetCommentByIdHandler((s))).Methods(http.MethodGet)
+	r.HandleFunc("/posts/{id}/comments/{commentId}", handlers.UpdateCommentHandler((s))).Methods(http.MethodPut)
+	r.HandleFunc("/posts/{id}/comments/{commentId}", handlers.DeleteCommentHandler((s))).Methods(http.MethodDelete)
+	r.HandleFunc("/posts/{id}/reactions/{kind}", handlers.AddReactionHandler((s))).Methods(http.MethodPut)
+	r.HandleFunc("/posts/{id}/reactions/{kind}", handlers.RemoveReactionHandler((s))).Methods(http.MethodDelete)
+	r.HandleFunc("/posts/{id}/reposts", handlers.RepostHandler((s))).Methods(http.MethodPost)
+	r.HandleFunc("/posts/{id}/reposts", handlers.UndoRepostHandler((s))).Methods(http.MethodDelete)
+	r.HandleFunc("/posts/{id}/poll/votes", handlers.VoteHandler((s))).Methods(http.MethodPost)
+	r.HandleFunc("/posts/{id}/report", handlers.ReportPostHandler((s))).Methods(http.MethodPost)
+	r.HandleFunc("/moderation/reports", handlers.ListReportedPostsHandler((s))).Methods(http.MethodGet)
+	r.HandleFunc("/moderation/posts/{id}/actions", handlers.ModeratePostHandler((s))).Methods(http.MethodPost)
+	r.HandleFunc("/moderation/actions", handlers.ListModerationActionsHandler((s))).Methods(http.MethodGet)
+	r.HandleFunc("/posts/{id}/revisions", handlers.ListPostRevisionsHandler((s))).Methods(http.MethodGet)
+	r.HandleFunc("/posts/{id}/revisions/diff", handlers.DiffPostRevisionsHandler((s))).Methods(http.MethodGet)
+	r.HandleFunc("/posts/{id}/revisions/{revision:[0-9]+}", handlers.GetPostRevisionHandler((s))).Methods(http.MethodGet)
+	r.HandleFunc("/tags/{tag}/posts", handlers.ListTagPostsHandler((s))).Methods(http.MethodGet)
+
+	// clients subscribe to "posts/{id}" in order to receive the events of that post
+	r.HandleFunc("/ws", s.Hub().HandleWebSocket)
 }
\ No newline at end of file
@@ -0,0 +1,26 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	// policy for content written by users: it removes scripts, styles, event handlers
+	// and any url that is not http, https or mailto
+	policy = bluemonday.UGCPolicy().RequireNoReferrerOnLinks(true)
+)
+
+// Render converts the markdown into HTML that is safe to be displayed by the web clients
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
@@ -1,24 +1,36 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/emavillamayorpsh/rest-ws/apierror"
 	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
 	"github.com/emavillamayorpsh/rest-ws/server"
+	"github.com/emavillamayorpsh/rest-ws/storage"
 	"github.com/golang-jwt/jwt"
 )
 
 var (
+	// the whole path must match, a path param like /tags/login/posts doesn't skip the token
 	NO_AUTH_NEEDED = []string	{
-		"login",
-		"signup",
+		"/login",
+		"/signup",
 	}
 )
 
+var accountSuspended = apierror.New(http.StatusForbidden, apierror.CodeForbidden, "Your account is suspended")
+
 func shouldCheckToken(route string) bool {
+	// the signed urls of the local storage, only at the start of the path so no other route can skip the token
+	if strings.HasPrefix(route, storage.LOCAL_URL_PREFIX) {
+		return false
+	}
 	for _, p := range NO_AUTH_NEEDED {
-		if strings.Contains(route, p) {
+		if route == p {
 			return false
 		}
 	}
@@ -41,18 +53,41 @@ func CheckAuthMiddleware(s server.Server) func (h http.Handler) http.Handler {
 			tokenString := strings.TrimSpace(r.Header.Get("Authorization"))
 
 			// CHECK IF TOKEN IS VALID
-			_, err := jwt.ParseWithClaims(tokenString, &models.AppClaims{}, func(t *jwt.Token) (interface{}, error) {
+			token, err := jwt.ParseWithClaims(tokenString, &models.AppClaims{}, func(t *jwt.Token) (interface{}, error) {
 				return []byte(s.Config().JWTSecret), nil
 			})
 
 			// IN CASE TOKEN INVALID RETURN ERROR
 			if err != nil {
-				http.Error(w, err.Error(), http.StatusUnauthorized)
+				apierror.Write(w, r, apierror.InvalidToken(err))
 				return
 			}
 
-			// IN CASE TOKEN VALID , IT MOVES TO THE NEXT MIDDLEWARE
-			next.ServeHTTP(w,r)
+			claims, ok := token.Claims.(*models.AppClaims)
+			if !ok || !token.Valid {
+				apierror.Write(w, r, apierror.InvalidToken(nil))
+				return
+			}
+
+			// the suspended users can still read, but their tokens can't change anything
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				user, err := repository.GetUserById(r.Context(), claims.UserId)
+				if errors.Is(err, repository.ErrNotFound) {
+					apierror.Write(w, r, apierror.InvalidToken(err))
+					return
+				}
+				if err != nil {
+					apierror.Write(w, r, err)
+					return
+				}
+				if user.SuspendedAt != nil {
+					apierror.Write(w, r, accountSuspended)
+					return
+				}
+			}
+
+			// IN CASE TOKEN VALID , IT MOVES TO THE NEXT MIDDLEWARE WITH THE USER IN THE CONTEXT
+			next.ServeHTTP(w, r.WithContext(requestctx.WithUserId(r.Context(), claims.UserId)))
 		})
 	}
 }
\ No newline at end of file
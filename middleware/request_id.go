@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	REQUEST_ID_HEADER = "X-Request-Id"
+)
+
+// ids received from the client are only accepted when they are short and safe to be logged
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestIDMiddleware assigns an id to every request, it is returned in the response headers
+// and in the body of the errors so a client report can be matched with our logs
+func RequestIDMiddleware() func (h http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(REQUEST_ID_HEADER)
+			if !validRequestID.MatchString(id) {
+				id = ksuid.New().String()
+			}
+
+			w.Header().Set(REQUEST_ID_HEADER, id)
+			next.ServeHTTP(w, r.WithContext(requestctx.WithRequestID(r.Context(), id)))
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// BookmarkCollection groups the posts saved by an user
+type BookmarkCollection struct {
+	Id            string
+	UserId        string
+	Name          string
+	CreatedAt     time.Time
+	BookmarkCount int
+}
+
+// Bookmark is a post saved in a collection, it is kept when the post is deleted
+// or stops being visible to the user
+type Bookmark struct {
+	CollectionId string
+	PostId       string
+	CreatedAt    time.Time
+}
+
+// CollectionQuery lists the collections of UserId, the newest first
+type CollectionQuery struct {
+	UserId string
+	Cursor *Cursor
+	Limit  uint64
+}
+
+// BookmarkQuery lists the bookmarks of a collection, the last saved first
+type BookmarkQuery struct {
+	CollectionId string
+	Cursor       *Cursor
+	Limit        uint64
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type Comment struct {
+	Id             string
+	PostId         string
+	ParentId       *string
+	UserId         string
+	CommentContent string
+	CommentHtml    string
+	CreatedAt      time.Time
+	ReplyCount     int
+	Replies        []*Comment
+}
+
+// CommentQuery lists the comments of a post, the top level ones when ParentId is empty
+// or the direct replies of ParentId
+type CommentQuery struct {
+	// the comments of the users blocked or muted by the viewer are not listed
+	ViewerId string
+	PostId   string
+	ParentId string
+	Cursor   *Cursor
+	Limit    uint64
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// RelatedUser is one of the users in the followers, following, blocked or muted lists of a user
+type RelatedUser struct {
+	UserId   string
+	Username string
+	// when the relation started
+	CreatedAt time.Time
+}
+
+// FollowQuery lists the followers of UserId or, when Following is true, the users that UserId follows.
+// The newest follows are listed first
+type FollowQuery struct {
+	UserId    string
+	Following bool
+	Cursor    *Cursor
+	Limit     uint64
+}
+
+// UserListQuery lists the users blocked or muted by UserId, the newest first
+type UserListQuery struct {
+	UserId string
+	Cursor *Cursor
+	Limit  uint64
+}
+
+// TimelineQuery lists the home timeline of UserId, the newest posts first
+type TimelineQuery struct {
+	UserId string
+	Cursor *Cursor
+	Limit  uint64
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// MediaStatus is the state of the generation of the variants, only the images have variants
+// and they are generated once the image is attached to a post
+type MediaStatus string
+
+const (
+	MediaStatusPending    MediaStatus = "pending"
+	MediaStatusProcessing MediaStatus = "processing"
+	MediaStatusReady      MediaStatus = "ready"
+	MediaStatusFailed     MediaStatus = "failed"
+)
+
+// Media is an uploaded file, its content is in the storage under StorageKey.
+// PostId is nil until it is attached to a post
+type Media struct {
+	Id          string
+	UserId      string
+	PostId      *string
+	StorageKey  string
+	ContentType string
+	FileName    string
+	Size        int64
+	Status      MediaStatus
+	Variants    []MediaVariant
+	CreatedAt   time.Time
+}
+
+// MediaVariant is a resized copy of an image, like its thumbnail
+type MediaVariant struct {
+	Name        string
+	StorageKey  string
+	ContentType string
+	Width       int
+	Height      int
+	Size        int64
+}
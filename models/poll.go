@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Poll is attached to a post, its id is the id of the post. ClosedAt is set when
+// the scheduler closes it, the votes are rejected since ClosesAt anyway
+type Poll struct {
+	PostId   string
+	Multiple bool
+	ClosesAt *time.Time
+	ClosedAt *time.Time
+	Options  []PollOption
+	// users that voted, with multiple choice it isn't the sum of the votes of the options
+	Voters int
+	// ids of the options voted by the current user
+	Mine []string
+}
+
+func (poll *Poll) IsClosed(now time.Time) bool {
+	return poll.ClosedAt != nil || (poll.ClosesAt != nil && !now.Before(*poll.ClosesAt))
+}
+
+// HasOption tells if the option belongs to the poll
+func (poll *Poll) HasOption(id string) bool {
+	for _, option := range poll.Options {
+		if option.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+type PollOption struct {
+	Id    string
+	Text  string
+	Votes int
+}
@@ -2,9 +2,111 @@ package models
 
 import "time"
 
+type PostStatus string
+
+const (
+	// only visible to its author
+	StatusDraft PostStatus = "draft"
+	// only visible to its author until PublishAt
+	StatusScheduled PostStatus = "scheduled"
+	StatusPublished PostStatus = "published"
+)
+
+func IsPostStatus(status string) bool {
+	switch PostStatus(status) {
+	case StatusDraft, StatusScheduled, StatusPublished:
+		return true
+	}
+	return false
+}
+
+type PostVisibility string
+
+const (
+	VisibilityPublic PostVisibility = "public"
+	// only visible to the followers of its author
+	VisibilityFollowers PostVisibility = "followers"
+	// only visible to its author
+	VisibilityPrivate PostVisibility = "private"
+)
+
+func IsPostVisibility(visibility string) bool {
+	switch PostVisibility(visibility) {
+	case VisibilityPublic, VisibilityFollowers, VisibilityPrivate:
+		return true
+	}
+	return false
+}
+
+// PostSchedule is the publication state of a post
+type PostSchedule struct {
+	Status PostStatus
+	PublishAt *time.Time
+}
+
 type Post struct {
-	Id string `json:"id"`
-	PostContent string `json:"post_content"`
-	CreatedAt time.Time `json:"created_at"`
-	UserId 		string	`json:"user_id"`
+	Id string
+	PostContent string
+	PostHtml string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// starts at 1 and is incremented on every update
+	Revision int
+	UserId 		string
+	// only set for the posts in the trash
+	DeletedAt *time.Time
+	Status PostStatus
+	// when a scheduled post will be published
+	PublishAt *time.Time
+	PublishedAt *time.Time
+	Visibility PostVisibility
+	// set for the reposts and the quotes, the reposts don't have content
+	RepostOfId *string
+	// set when a moderator hides the post, or when it got too many reports
+	HiddenAt *time.Time
+}
+
+// PostEntities are the #tags and @mentions of the content of a post, they are stored with the content
+type PostEntities struct {
+	Tags []string
+	// the mentioned users, without the author
+	MentionIds []string
+	// filled when they are stored, the users that were not mentioned before and can see the post
+	AddedMentionIds []string
+}
+
+// PostAttachments are stored with a new post in the same transaction, a failure stores none of them
+type PostAttachments struct {
+	// the media of the author, in the order they are shown
+	MediaIds []string
+	Poll *Poll
+}
+
+// IsRepost tells if the post only shares another post, without adding content to it
+func (p *Post) IsRepost() bool {
+	return p.RepostOfId != nil && p.PostContent == ""
+}
+
+// RepostCounts are the times a post was reposted and quoted
+type RepostCounts struct {
+	Reposts int
+	Quotes  int
+}
+
+// ListedAt is the time used to sort the posts in the listings, the posts are listed
+// when they are published and the drafts when they were created
+func (p *Post) ListedAt() time.Time {
+	if p.PublishedAt != nil {
+		return *p.PublishedAt
+	}
+	return p.CreatedAt
+}
+
+// PostRevision is a copy of the content of a post after it was created or updated
+type PostRevision struct {
+	PostId string
+	Revision int
+	PostContent string
+	PostHtml string
+	CreatedAt time.Time
 }
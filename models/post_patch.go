@@ -0,0 +1,17 @@
+package models
+
+// PostPatch has the fields of a post that can be changed with a merge patch,
+// nil fields were not sent by the client and are left as they are
+type PostPatch struct {
+	PostContent *string
+	PostHtml    *string
+	// when it is not nil the status and publish_at are replaced
+	Schedule *PostSchedule
+	Visibility *PostVisibility
+	// the entities of the new content, set together with PostContent
+	Entities *PostEntities
+}
+
+func (p *PostPatch) IsEmpty() bool {
+	return p.PostContent == nil && p.PostHtml == nil && p.Schedule == nil && p.Visibility == nil
+}
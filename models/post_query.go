@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type PostSort string
+
+const (
+	SortNewest PostSort = "newest"
+	SortOldest PostSort = "oldest"
+)
+
+// PostQuery holds the criteria used to list posts, every empty field is ignored
+type PostQuery struct {
+	// the user that asks for the posts, the drafts and scheduled posts are only listed to their author
+	// and the posts of the users blocked or muted by the viewer are not listed
+	ViewerId      string
+	UserId        string
+	Tag           string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Contains      string
+	// list the posts in the trash instead of the visible ones
+	Deleted bool
+	Sort    PostSort
+	Cursor  *Cursor
+	Limit   uint64
+}
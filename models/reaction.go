@@ -0,0 +1,22 @@
+package models
+
+// ReactionKinds is the fixed set of emoji the users can react with
+var ReactionKinds = map[string]string{
+	"like":  "👍",
+	"love":  "❤️",
+	"laugh": "😂",
+	"wow":   "😮",
+	"sad":   "😢",
+	"angry": "😡",
+}
+
+func IsReactionKind(kind string) bool {
+	_, ok := ReactionKinds[kind]
+	return ok
+}
+
+// ReactionSummary is the aggregate of the reactions of a post, Mine has the kinds used by the current user
+type ReactionSummary struct {
+	Counts map[string]int
+	Mine   []string
+}
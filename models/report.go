@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// ReportReasons are the reasons the users can report a post for
+var ReportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+func IsReportReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+)
+
+// ModerationActionKind is the decision taken on a reported post, the open reports of the
+// post are resolved with it
+type ModerationActionKind string
+
+const (
+	// the reports were unfounded, the post is shown again if it was hidden
+	ActionDismiss ModerationActionKind = "dismiss"
+	ActionHide    ModerationActionKind = "hide"
+	// the author is suspended, which hides all its posts
+	ActionSuspend ModerationActionKind = "suspend"
+	// done by the server when the post reaches the auto-hide threshold
+	ActionAutoHide ModerationActionKind = "auto_hide"
+)
+
+func IsModerationAction(action string) bool {
+	switch ModerationActionKind(action) {
+	case ActionDismiss, ActionHide, ActionSuspend:
+		return true
+	}
+	return false
+}
+
+type Report struct {
+	Id         string
+	PostId     string
+	ReporterId string
+	Reason     string
+	Details    string
+	CreatedAt  time.Time
+}
+
+// ReportedPost is an item of the moderation queue, the open reports of a post aggregated
+type ReportedPost struct {
+	PostId      string
+	AuthorId    string
+	PostContent string
+	PostHtml    string
+	HiddenAt    *time.Time
+	Reports     int
+	// number of open reports of every reason
+	Reasons         map[string]int
+	FirstReportedAt time.Time
+	LastReportedAt  time.Time
+}
+
+// ModerationAction records who took a decision and when, ModeratorId is nil for the automatic ones
+type ModerationAction struct {
+	Id          string
+	PostId      string
+	AuthorId    string
+	ModeratorId *string
+	Action      ModerationActionKind
+	Note        string
+	CreatedAt   time.Time
+}
+
+// ModerationQuery lists the queue, the posts reported last first, or the actions, the newest first
+type ModerationQuery struct {
+	Cursor *Cursor
+	Limit  uint64
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+)
+
+// PostSearchResult is a post matching a search, with its relevance and the fragments that matched
+type PostSearchResult struct {
+	Post
+	Rank    float32
+	Snippet string
+}
+
+type PostSearchQuery struct {
+	ViewerId string
+	Query    string
+	Cursor   *SearchCursor
+	Limit    uint64
+}
+
+// SearchCursor points at the last result of a page, results are ordered by (rank, id)
+type SearchCursor struct {
+	Rank float32
+	Id   string
+}
+
+func (c *SearchCursor) Encode() string {
+	raw := strconv.FormatFloat(float64(c.Rank), 'g', -1, 32) + "|" + c.Id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeSearchCursor(value string) (*SearchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	rank, err := strconv.ParseFloat(parts[0], 32)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &SearchCursor{
+		Rank: float32(rank),
+		Id:   parts[1],
+	}, nil
+}
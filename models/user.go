@@ -1,7 +1,43 @@
 package models
 
+import "time"
+
+// User is the stored user, it is never sent to the clients: the dto package has its
+// representations. The email and password are excluded from JSON as a second safeguard
 type User struct {
-	Id string `json:"id"`
-	Email string `json:"email"`
-	Password string `json:"password"`
-}
\ No newline at end of file
+	Id string
+	Email string `json:"-"`
+	// optional handle used to mention the user in the posts
+	Username string
+	Password string `json:"-"`
+	DisplayName string
+	Bio string
+	AvatarUrl string
+	// RoleUser or RoleModerator
+	Role string
+	SuspendedAt *time.Time
+	CreatedAt time.Time
+}
+
+func (u *User) IsModerator() bool {
+	return u.Role == RoleModerator
+}
+
+// UserPatch has the fields of the profile that can be changed, nil fields were not sent by the client.
+// An empty Username removes it
+type UserPatch struct {
+	Username    *string
+	DisplayName *string
+	Bio         *string
+	AvatarUrl   *string
+}
+
+func (p *UserPatch) IsEmpty() bool {
+	return p.Username == nil && p.DisplayName == nil && p.Bio == nil && p.AvatarUrl == nil
+}
+
+// UserStats are the counters shown in the public profile
+type UserStats struct {
+	FollowerCount  int
+	FollowingCount int
+}
@@ -0,0 +1,28 @@
+package models
+
+import "strings"
+
+// types of the messages sent through the websocket
+const (
+	EventPostCreated    = "post_created"
+	EventCommentCreated = "comment_created"
+	EventMentioned      = "mentioned"
+	EventReposted       = "reposted"
+	EventPollUpdated    = "poll_updated"
+)
+
+type WebsocketMessage struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload"`
+}
+
+// PostTopic is the topic a websocket client subscribes to in order to receive the events of a post
+func PostTopic(postId string) string {
+	return "posts/" + postId
+}
+
+// PostIdFromTopic returns the id of the post of a topic built with PostTopic
+func PostIdFromTopic(topic string) (string, bool) {
+	postId := strings.TrimPrefix(topic, "posts/")
+	return postId, postId != topic && postId != ""
+}
@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/emavillamayorpsh/rest-ws/dto"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/websocket"
+)
+
+// PostPublished adds the post to the timelines, sends the post_created event to the users that
+// can see the post and notifies the users mentioned in it. It is called when a post becomes visible: when it is created as
+// published, when a draft is published or when the scheduler publishes it.
+// The users that blocked or muted the author don't receive any of the events
+func PostPublished(ctx context.Context, hub *websocket.Hub, post *models.Post) error {
+	if err := repository.FanOutPost(ctx, post); err != nil {
+		return err
+	}
+
+	hidingIds, err := repository.ListHidingUserIds(ctx, post.UserId)
+	if err != nil {
+		return err
+	}
+
+	message := models.WebsocketMessage{
+		Type:    models.EventPostCreated,
+		Payload: dto.NewPost(post, dto.PostDetails{}),
+	}
+
+	switch post.Visibility {
+	case models.VisibilityPublic:
+		hub.BroadcastExcept(message, hidingIds)
+	case models.VisibilityFollowers:
+		followerIds, err := repository.ListFollowerIds(ctx, post.UserId)
+		if err != nil {
+			return err
+		}
+		hub.SendToUsers(append(without(followerIds, hidingIds), post.UserId), message)
+	default:
+		hub.SendToUser(post.UserId, message)
+	}
+
+	userIds, err := repository.ListPostMentions(ctx, post.Id)
+	if err != nil {
+		return err
+	}
+	mentioned(hub, post, without(userIds, hidingIds))
+	return nil
+}
+
+// CommentCreated sends the comment to the subscribers of its post, except the users that blocked or muted its author
+func CommentCreated(ctx context.Context, hub *websocket.Hub, comment *models.Comment) error {
+	hidingIds, err := repository.ListHidingUserIds(ctx, comment.UserId)
+	if err != nil {
+		return err
+	}
+
+	return publishToViewers(ctx, hub, comment.PostId, models.WebsocketMessage{
+		Type:    models.EventCommentCreated,
+		Payload: dto.NewComment(comment),
+	}, hidingIds)
+}
+
+// PollUpdated sends the tallies of the poll to the subscribers of its post, the voters are not included
+func PollUpdated(ctx context.Context, hub *websocket.Hub, poll *models.Poll) error {
+	return publishToViewers(ctx, hub, poll.PostId, models.WebsocketMessage{
+		Type:    models.EventPollUpdated,
+		Payload: dto.NewPollEvent(poll),
+	}, nil)
+}
+
+// publishToViewers sends the message to the subscribers of the post that can still see it, except the excluded
+// users. The access is checked again because it can change after the subscription: the post is hidden or its
+// visibility changes, the subscriber unfollows, blocks or mutes the author
+func publishToViewers(ctx context.Context, hub *websocket.Hub, postId string, message models.WebsocketMessage, excludedUserIds []string) error {
+	topic := models.PostTopic(postId)
+	subscriberIds := hub.TopicUserIds(topic)
+	if len(subscriberIds) == 0 {
+		return nil
+	}
+
+	viewerIds, err := repository.ListPostViewerIds(ctx, postId, subscriberIds)
+	if err != nil {
+		return err
+	}
+	hub.PublishToUsers(topic, message, without(viewerIds, excludedUserIds))
+	return nil
+}
+
+// CanSubscribe is the websocket.TopicAuthorizer of the hub, the users can only receive
+// the events of the posts they can see. The check is done when the client subscribes and
+// again for every event, see publishToViewers
+func CanSubscribe(userId string, topic string) bool {
+	postId, ok := models.PostIdFromTopic(topic)
+	if !ok {
+		return false
+	}
+	_, err := repository.GetPostById(context.Background(), postId, userId)
+	return err == nil
+}
+
+// Reposted notifies the author of the original post, unless the author blocked or muted the user that reposted it
+func Reposted(ctx context.Context, hub *websocket.Hub, repost *models.Post, original *models.Post) error {
+	if repost.UserId == original.UserId {
+		return nil
+	}
+
+	hidingIds, err := repository.ListHidingUserIds(ctx, repost.UserId)
+	if err != nil {
+		return err
+	}
+	if len(without([]string{original.UserId}, hidingIds)) == 0 {
+		return nil
+	}
+
+	hub.SendToUser(original.UserId, models.WebsocketMessage{
+		Type: models.EventReposted,
+		Payload: dto.RepostEvent{
+			PostId:   original.Id,
+			RepostId: repost.Id,
+			UserId:   repost.UserId,
+			Quote:    !repost.IsRepost(),
+		},
+	})
+	return nil
+}
+
+// Mentioned notifies the users that were mentioned in the post, except the ones that muted its author
+func Mentioned(ctx context.Context, hub *websocket.Hub, post *models.Post, userIds []string) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+
+	hidingIds, err := repository.ListHidingUserIds(ctx, post.UserId)
+	if err != nil {
+		return err
+	}
+	mentioned(hub, post, without(userIds, hidingIds))
+	return nil
+}
+
+func mentioned(hub *websocket.Hub, post *models.Post, userIds []string) {
+	for _, userId := range userIds {
+		hub.SendToUser(userId, models.WebsocketMessage{
+			Type:    models.EventMentioned,
+			Payload: dto.MentionEvent{PostId: post.Id, AuthorId: post.UserId},
+		})
+	}
+}
+
+// without returns the ids that are not in excluded
+func without(ids []string, excluded []string) []string {
+	skip := make(map[string]bool, len(excluded))
+	for _, id := range excluded {
+		skip[id] = true
+	}
+
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !skip[id] {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
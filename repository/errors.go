@@ -0,0 +1,13 @@
+package repository
+
+import "errors"
+
+// errors returned by the implementations of Repository, so the callers can react
+// to them without knowing which database is behind
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrForbidden = errors.New("forbidden")
+	ErrConflict  = errors.New("conflict")
+	// the resource was modified after the version the caller expected
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
@@ -2,19 +2,95 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/emavillamayorpsh/rest-ws/models"
 )
 
+// PostSearcher is split from Repository so the search can be backed by another implementation
+type PostSearcher interface {
+	SearchPosts(ctx context.Context, query *models.PostSearchQuery) ([]*models.PostSearchResult, error)
+}
+
+// TimelineStore is split from Repository so the home timelines can be built when they are read
+// (fan-out-on-read) or stored for every follower when the posts are published (fan-out-on-write)
+type TimelineStore interface {
+	// ListTimeline returns the published posts of the users followed by query.UserId, and its own ones,
+	// that the user can still see
+	ListTimeline(ctx context.Context, query *models.TimelineQuery) ([]*models.Post, error)
+	// FanOutPost is called every time a post is published
+	FanOutPost(ctx context.Context, post *models.Post) error
+}
+
 type Repository interface {
+	PostSearcher
+	TimelineStore
 	InsertUser(ctx context.Context, user *models.User) error
 	GetUserById(ctx context.Context, id string) (*models.User , error)
 	GetUserByEmail(ctx context.Context, email string) (*models.User , error)
-	InsertPost(ctx context.Context, post *models.Post) error
-	GetPostById(ctx context.Context, id string) (*models.Post , error)
-	UpdatePost(ctx context.Context, post *models.Post) error
+	UpdateUser(ctx context.Context, id string, patch *models.UserPatch) (*models.User, error)
+	GetUserStats(ctx context.Context, id string) (*models.UserStats, error)
+	InsertPost(ctx context.Context, post *models.Post, entities *models.PostEntities, attachments *models.PostAttachments) error
+	GetPostById(ctx context.Context, id string, viewerId string) (*models.Post , error)
+	ListPostViewerIds(ctx context.Context, postId string, userIds []string) ([]string, error)
+	UpdatePost(ctx context.Context, post *models.Post, entities *models.PostEntities, expectedRevisions []int) error
+	PatchPost(ctx context.Context, id string, userId string, patch *models.PostPatch, expectedRevisions []int) (*models.Post, error)
 	DeletePost(ctx context.Context, id string, userId string) error
-	ListPost (ctx context.Context, page uint64) ([]*models.Post, error)
+	RestorePost(ctx context.Context, id string, userId string) error
+	PurgeDeletedPosts(ctx context.Context, deletedBefore time.Time) (int64, []string, error)
+	PublishDuePosts(ctx context.Context, limit int) ([]*models.Post, error)
+	ListPost (ctx context.Context, query *models.PostQuery) ([]*models.Post, error)
+	InsertComment(ctx context.Context, comment *models.Comment) error
+	GetCommentById(ctx context.Context, postId string, id string) (*models.Comment, error)
+	UpdateComment(ctx context.Context, comment *models.Comment) error
+	DeleteComment(ctx context.Context, postId string, id string, userId string) error
+	ListComments(ctx context.Context, query *models.CommentQuery) ([]*models.Comment, error)
+	ListCommentReplies(ctx context.Context, parentIds []string, depth int, viewerId string) ([]*models.Comment, error)
+	CountComments(ctx context.Context, postIds []string) (map[string]int, error)
+	AddReaction(ctx context.Context, postId string, userId string, kind string) error
+	RemoveReaction(ctx context.Context, postId string, userId string, kind string) error
+	GetReactionSummaries(ctx context.Context, postIds []string, viewerId string) (map[string]*models.ReactionSummary, error)
+	GetUserIdsByUsernames(ctx context.Context, usernames []string) (map[string]string, error)
+	ListPostMentions(ctx context.Context, postId string) ([]string, error)
+	Follow(ctx context.Context, followerId string, followeeId string) error
+	Unfollow(ctx context.Context, followerId string, followeeId string) error
+	ListFollows(ctx context.Context, query *models.FollowQuery) ([]*models.RelatedUser, error)
+	ListFollowerIds(ctx context.Context, userId string) ([]string, error)
+	Block(ctx context.Context, blockerId string, blockedId string) error
+	Unblock(ctx context.Context, blockerId string, blockedId string) error
+	IsBlocked(ctx context.Context, blockerId string, blockedId string) (bool, error)
+	Mute(ctx context.Context, muterId string, mutedId string) error
+	Unmute(ctx context.Context, muterId string, mutedId string) error
+	ListBlockedUsers(ctx context.Context, query *models.UserListQuery) ([]*models.RelatedUser, error)
+	ListMutedUsers(ctx context.Context, query *models.UserListQuery) ([]*models.RelatedUser, error)
+	ListHidingUserIds(ctx context.Context, userId string) ([]string, error)
+	GetPostRevision(ctx context.Context, postId string, revision int) (*models.PostRevision, error)
+	GetPostsByIds(ctx context.Context, ids []string, viewerId string) ([]*models.Post, error)
+	CountReposts(ctx context.Context, postIds []string) (map[string]*models.RepostCounts, error)
+	DeleteRepost(ctx context.Context, postId string, userId string) error
+	GetPolls(ctx context.Context, postIds []string, viewerId string) (map[string]*models.Poll, error)
+	Vote(ctx context.Context, postId string, userId string, optionIds []string) error
+	CloseDuePolls(ctx context.Context, limit int) ([]*models.Poll, error)
+	InsertMedia(ctx context.Context, media *models.Media) error
+	GetMediaById(ctx context.Context, id string) (*models.Media, error)
+	ListPostMedia(ctx context.Context, postIds []string) (map[string][]*models.Media, error)
+	ClaimPendingMedia(ctx context.Context, limit int, staleAfter time.Duration) ([]*models.Media, error)
+	SaveMediaVariants(ctx context.Context, mediaId string, variants []models.MediaVariant) error
+	SetMediaStatus(ctx context.Context, mediaId string, status models.MediaStatus) error
+	PurgeUnattachedMedia(ctx context.Context, createdBefore time.Time) ([]string, error)
+	InsertReport(ctx context.Context, report *models.Report, autoHideThreshold int) error
+	ListReportedPosts(ctx context.Context, query *models.ModerationQuery) ([]*models.ReportedPost, error)
+	ModeratePost(ctx context.Context, action *models.ModerationAction) error
+	ListModerationActions(ctx context.Context, query *models.ModerationQuery) ([]*models.ModerationAction, error)
+	InsertCollection(ctx context.Context, collection *models.BookmarkCollection) error
+	GetCollection(ctx context.Context, userId string, id string) (*models.BookmarkCollection, error)
+	RenameCollection(ctx context.Context, userId string, id string, name string) (*models.BookmarkCollection, error)
+	DeleteCollection(ctx context.Context, userId string, id string) error
+	ListCollections(ctx context.Context, query *models.CollectionQuery) ([]*models.BookmarkCollection, error)
+	AddBookmark(ctx context.Context, collectionId string, postId string) error
+	RemoveBookmark(ctx context.Context, collectionId string, postId string) error
+	ListBookmarks(ctx context.Context, query *models.BookmarkQuery) ([]*models.Bookmark, error)
+	ListPostRevisions(ctx context.Context, postId string, before int, limit uint64) ([]*models.PostRevision, error)
 	Close() error
 }
 
@@ -40,22 +116,269 @@ func GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	return implementation.GetUserByEmail(ctx, email)
 }
 
-func InsertPost(ctx context.Context, post *models.Post) error {
-	return implementation.InsertPost(ctx, post)
+func UpdateUser(ctx context.Context, id string, patch *models.UserPatch) (*models.User, error) {
+	return implementation.UpdateUser(ctx, id, patch)
+}
+
+func GetUserStats(ctx context.Context, id string) (*models.UserStats, error) {
+	return implementation.GetUserStats(ctx, id)
+}
+
+func InsertPost(ctx context.Context, post *models.Post, entities *models.PostEntities, attachments *models.PostAttachments) error {
+	return implementation.InsertPost(ctx, post, entities, attachments)
 }
 
-func GetPostById(ctx context.Context, id string) (*models.Post, error) {
-	return implementation.GetPostById(ctx, id)
+// GetPostById returns ErrNotFound when the post is not visible to the viewer
+func GetPostById(ctx context.Context, id string, viewerId string) (*models.Post, error) {
+	return implementation.GetPostById(ctx, id, viewerId)
 }
 
-func UpdatePost(ctx context.Context, post *models.Post) error {
-	return implementation.UpdatePost(ctx, post)
+func ListPostViewerIds(ctx context.Context, postId string, userIds []string) ([]string, error) {
+	return implementation.ListPostViewerIds(ctx, postId, userIds)
+}
+
+// UpdatePost only updates the post when its revision is one of expectedRevisions, or always when it is empty
+func UpdatePost(ctx context.Context, post *models.Post, entities *models.PostEntities, expectedRevisions []int) error {
+	return implementation.UpdatePost(ctx, post, entities, expectedRevisions)
+}
+
+// PatchPost only changes the fields present in the patch, with the same revision check of UpdatePost
+func PatchPost(ctx context.Context, id string, userId string, patch *models.PostPatch, expectedRevisions []int) (*models.Post, error) {
+	return implementation.PatchPost(ctx, id, userId, patch, expectedRevisions)
 }
 
 func DeletePost(ctx context.Context, id string, userId string) error {
 	return implementation.DeletePost(ctx, id, userId)
 }
 
-func ListPost(ctx context.Context, page uint64) ([]*models.Post, error) {
-	return implementation.ListPost(ctx, page)
-}
\ No newline at end of file
+func RestorePost(ctx context.Context, id string, userId string) error {
+	return implementation.RestorePost(ctx, id, userId)
+}
+
+func PurgeDeletedPosts(ctx context.Context, deletedBefore time.Time) (int64, []string, error) {
+	return implementation.PurgeDeletedPosts(ctx, deletedBefore)
+}
+
+func PublishDuePosts(ctx context.Context, limit int) ([]*models.Post, error) {
+	return implementation.PublishDuePosts(ctx, limit)
+}
+
+func ListPost(ctx context.Context, query *models.PostQuery) ([]*models.Post, error) {
+	return implementation.ListPost(ctx, query)
+}
+
+func SearchPosts(ctx context.Context, query *models.PostSearchQuery) ([]*models.PostSearchResult, error) {
+	return implementation.SearchPosts(ctx, query)
+}
+
+func ListTimeline(ctx context.Context, query *models.TimelineQuery) ([]*models.Post, error) {
+	return implementation.ListTimeline(ctx, query)
+}
+
+func FanOutPost(ctx context.Context, post *models.Post) error {
+	return implementation.FanOutPost(ctx, post)
+}
+
+func InsertComment(ctx context.Context, comment *models.Comment) error {
+	return implementation.InsertComment(ctx, comment)
+}
+
+func GetCommentById(ctx context.Context, postId string, id string) (*models.Comment, error) {
+	return implementation.GetCommentById(ctx, postId, id)
+}
+
+func UpdateComment(ctx context.Context, comment *models.Comment) error {
+	return implementation.UpdateComment(ctx, comment)
+}
+
+func DeleteComment(ctx context.Context, postId string, id string, userId string) error {
+	return implementation.DeleteComment(ctx, postId, id, userId)
+}
+
+func ListComments(ctx context.Context, query *models.CommentQuery) ([]*models.Comment, error) {
+	return implementation.ListComments(ctx, query)
+}
+
+func ListCommentReplies(ctx context.Context, parentIds []string, depth int, viewerId string) ([]*models.Comment, error) {
+	return implementation.ListCommentReplies(ctx, parentIds, depth, viewerId)
+}
+
+func CountComments(ctx context.Context, postIds []string) (map[string]int, error) {
+	return implementation.CountComments(ctx, postIds)
+}
+
+func AddReaction(ctx context.Context, postId string, userId string, kind string) error {
+	return implementation.AddReaction(ctx, postId, userId, kind)
+}
+
+func RemoveReaction(ctx context.Context, postId string, userId string, kind string) error {
+	return implementation.RemoveReaction(ctx, postId, userId, kind)
+}
+
+func GetReactionSummaries(ctx context.Context, postIds []string, viewerId string) (map[string]*models.ReactionSummary, error) {
+	return implementation.GetReactionSummaries(ctx, postIds, viewerId)
+}
+
+func ListPostMentions(ctx context.Context, postId string) ([]string, error) {
+	return implementation.ListPostMentions(ctx, postId)
+}
+
+func GetUserIdsByUsernames(ctx context.Context, usernames []string) (map[string]string, error) {
+	return implementation.GetUserIdsByUsernames(ctx, usernames)
+}
+
+func Follow(ctx context.Context, followerId string, followeeId string) error {
+	return implementation.Follow(ctx, followerId, followeeId)
+}
+
+func Unfollow(ctx context.Context, followerId string, followeeId string) error {
+	return implementation.Unfollow(ctx, followerId, followeeId)
+}
+
+func ListFollows(ctx context.Context, query *models.FollowQuery) ([]*models.RelatedUser, error) {
+	return implementation.ListFollows(ctx, query)
+}
+
+func ListFollowerIds(ctx context.Context, userId string) ([]string, error) {
+	return implementation.ListFollowerIds(ctx, userId)
+}
+
+func Block(ctx context.Context, blockerId string, blockedId string) error {
+	return implementation.Block(ctx, blockerId, blockedId)
+}
+
+func Unblock(ctx context.Context, blockerId string, blockedId string) error {
+	return implementation.Unblock(ctx, blockerId, blockedId)
+}
+
+func IsBlocked(ctx context.Context, blockerId string, blockedId string) (bool, error) {
+	return implementation.IsBlocked(ctx, blockerId, blockedId)
+}
+
+func Mute(ctx context.Context, muterId string, mutedId string) error {
+	return implementation.Mute(ctx, muterId, mutedId)
+}
+
+func Unmute(ctx context.Context, muterId string, mutedId string) error {
+	return implementation.Unmute(ctx, muterId, mutedId)
+}
+
+func ListBlockedUsers(ctx context.Context, query *models.UserListQuery) ([]*models.RelatedUser, error) {
+	return implementation.ListBlockedUsers(ctx, query)
+}
+
+func ListMutedUsers(ctx context.Context, query *models.UserListQuery) ([]*models.RelatedUser, error) {
+	return implementation.ListMutedUsers(ctx, query)
+}
+
+func ListHidingUserIds(ctx context.Context, userId string) ([]string, error) {
+	return implementation.ListHidingUserIds(ctx, userId)
+}
+
+func GetPostRevision(ctx context.Context, postId string, revision int) (*models.PostRevision, error) {
+	return implementation.GetPostRevision(ctx, postId, revision)
+}
+
+func ListPostRevisions(ctx context.Context, postId string, before int, limit uint64) ([]*models.PostRevision, error) {
+	return implementation.ListPostRevisions(ctx, postId, before, limit)
+}
+
+func GetPostsByIds(ctx context.Context, ids []string, viewerId string) ([]*models.Post, error) {
+	return implementation.GetPostsByIds(ctx, ids, viewerId)
+}
+
+func InsertCollection(ctx context.Context, collection *models.BookmarkCollection) error {
+	return implementation.InsertCollection(ctx, collection)
+}
+
+func GetCollection(ctx context.Context, userId string, id string) (*models.BookmarkCollection, error) {
+	return implementation.GetCollection(ctx, userId, id)
+}
+
+func RenameCollection(ctx context.Context, userId string, id string, name string) (*models.BookmarkCollection, error) {
+	return implementation.RenameCollection(ctx, userId, id, name)
+}
+
+func DeleteCollection(ctx context.Context, userId string, id string) error {
+	return implementation.DeleteCollection(ctx, userId, id)
+}
+
+func ListCollections(ctx context.Context, query *models.CollectionQuery) ([]*models.BookmarkCollection, error) {
+	return implementation.ListCollections(ctx, query)
+}
+
+func AddBookmark(ctx context.Context, collectionId string, postId string) error {
+	return implementation.AddBookmark(ctx, collectionId, postId)
+}
+
+func RemoveBookmark(ctx context.Context, collectionId string, postId string) error {
+	return implementation.RemoveBookmark(ctx, collectionId, postId)
+}
+
+func ListBookmarks(ctx context.Context, query *models.BookmarkQuery) ([]*models.Bookmark, error) {
+	return implementation.ListBookmarks(ctx, query)
+}
+
+func CountReposts(ctx context.Context, postIds []string) (map[string]*models.RepostCounts, error) {
+	return implementation.CountReposts(ctx, postIds)
+}
+
+func DeleteRepost(ctx context.Context, postId string, userId string) error {
+	return implementation.DeleteRepost(ctx, postId, userId)
+}
+
+func GetPolls(ctx context.Context, postIds []string, viewerId string) (map[string]*models.Poll, error) {
+	return implementation.GetPolls(ctx, postIds, viewerId)
+}
+
+func Vote(ctx context.Context, postId string, userId string, optionIds []string) error {
+	return implementation.Vote(ctx, postId, userId, optionIds)
+}
+
+func CloseDuePolls(ctx context.Context, limit int) ([]*models.Poll, error) {
+	return implementation.CloseDuePolls(ctx, limit)
+}
+
+func InsertMedia(ctx context.Context, media *models.Media) error {
+	return implementation.InsertMedia(ctx, media)
+}
+
+func GetMediaById(ctx context.Context, id string) (*models.Media, error) {
+	return implementation.GetMediaById(ctx, id)
+}
+
+func ListPostMedia(ctx context.Context, postIds []string) (map[string][]*models.Media, error) {
+	return implementation.ListPostMedia(ctx, postIds)
+}
+
+func ClaimPendingMedia(ctx context.Context, limit int, staleAfter time.Duration) ([]*models.Media, error) {
+	return implementation.ClaimPendingMedia(ctx, limit, staleAfter)
+}
+
+func SaveMediaVariants(ctx context.Context, mediaId string, variants []models.MediaVariant) error {
+	return implementation.SaveMediaVariants(ctx, mediaId, variants)
+}
+
+func SetMediaStatus(ctx context.Context, mediaId string, status models.MediaStatus) error {
+	return implementation.SetMediaStatus(ctx, mediaId, status)
+}
+
+func PurgeUnattachedMedia(ctx context.Context, createdBefore time.Time) ([]string, error) {
+	return implementation.PurgeUnattachedMedia(ctx, createdBefore)
+}
+
+func InsertReport(ctx context.Context, report *models.Report, autoHideThreshold int) error {
+	return implementation.InsertReport(ctx, report, autoHideThreshold)
+}
+
+func ListReportedPosts(ctx context.Context, query *models.ModerationQuery) ([]*models.ReportedPost, error) {
+	return implementation.ListReportedPosts(ctx, query)
+}
+
+func ModeratePost(ctx context.Context, action *models.ModerationAction) error {
+	return implementation.ModeratePost(ctx, action)
+}
+
+func ListModerationActions(ctx context.Context, query *models.ModerationQuery) ([]*models.ModerationAction, error) {
+	return implementation.ListModerationActions(ctx, query)
+}
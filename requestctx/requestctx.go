@@ -0,0 +1,32 @@
+package requestctx
+
+import "context"
+
+// values that the middlewares attach to the context of every request
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userIdKey
+)
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the id assigned to the request, or an empty string when there isn't one
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithUserId stores the id of the authenticated user, taken from the token
+func WithUserId(ctx context.Context, userId string) context.Context {
+	return context.WithValue(ctx, userIdKey, userId)
+}
+
+// UserId returns the id of the authenticated user, or an empty string for the routes without authentication
+func UserId(ctx context.Context) string {
+	userId, _ := ctx.Value(userIdKey).(string)
+	return userId
+}
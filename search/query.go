@@ -0,0 +1,71 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// markers placed around every match of a snippet, they are private use characters
+// so they can't collide with the content of the posts
+const (
+	StartMark = "\uE000"
+	StopMark  = "\uE001"
+)
+
+// Term is a single word, or a phrase when it has more than one word
+type Term struct {
+	Words  []string
+	Prefix bool
+}
+
+type Query struct {
+	Terms []Term
+}
+
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0
+}
+
+// Parse reads the "q" param of the search:
+//   - words are matched after stemming: cats matches cat
+//   - "quoted words" are matched as a phrase
+//   - word* matches every word starting with "word"
+//
+// every term must be present for a post to match
+func Parse(q string) Query {
+	var query Query
+
+	for i, chunk := range strings.Split(q, `"`) {
+		// odd chunks were inside of quotes
+		if i%2 == 1 {
+			if words := Words(chunk); len(words) > 0 {
+				query.Terms = append(query.Terms, Term{Words: words})
+			}
+			continue
+		}
+
+		for _, field := range strings.Fields(chunk) {
+			prefix := strings.HasSuffix(field, "*")
+			for _, word := range Words(field) {
+				query.Terms = append(query.Terms, Term{Words: []string{word}, Prefix: prefix})
+			}
+		}
+	}
+
+	return query
+}
+
+// Words keeps only letters and digits, so the words are always safe to be used in a tsquery
+func Words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Highlight escapes the snippet and replaces the markers with <mark> tags
+func Highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, StartMark, "<mark>")
+	return strings.ReplaceAll(escaped, StopMark, "</mark>")
+}
@@ -3,35 +3,81 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/emavillamayorpsh/rest-ws/database"
+	"github.com/emavillamayorpsh/rest-ws/imaging"
+	"github.com/emavillamayorpsh/rest-ws/models"
+	"github.com/emavillamayorpsh/rest-ws/notify"
 	"github.com/emavillamayorpsh/rest-ws/repository"
+	"github.com/emavillamayorpsh/rest-ws/storage"
+	"github.com/emavillamayorpsh/rest-ws/websocket"
 	"github.com/gorilla/mux"
 )
 
+const (
+	DEFAULT_MAX_POST_LENGTH = 10000
+	DEFAULT_TRASH_RETENTION = 30 * 24 * time.Hour
+	TRASH_PURGE_INTERVAL = time.Hour
+	SCHEDULER_INTERVAL = 15 * time.Second
+	// max number of scheduled posts published on every tick
+	SCHEDULER_BATCH_SIZE = 100
+	// max number of polls closed on every tick
+	POLL_CLOSE_BATCH_SIZE = 100
+	DEFAULT_MAX_MEDIA_SIZE = 10 << 20
+	DEFAULT_AUTO_HIDE_THRESHOLD = 5
+	MEDIA_PROCESSING_INTERVAL = 5 * time.Second
+	// max number of images processed on every tick
+	MEDIA_PROCESSING_BATCH_SIZE = 10
+	// the images processing for longer are taken again, the server that had them may have stopped
+	MEDIA_PROCESSING_TIMEOUT = 10 * time.Minute
+	DEFAULT_UNATTACHED_MEDIA_TTL = 24 * time.Hour
+)
+
 // config of the server in order to be executed
 
 type Config struct {
 	Port string
 	JWTSecret string
 	DatabaseUrl string
+	// max number of characters of the content of a post
+	MaxPostLength int
+	// time the deleted posts stay in the trash before being removed for good
+	TrashRetention time.Duration
+	// when true the updates of posts without If-Match are rejected
+	RequireIfMatch bool
+	// where the content of the uploaded media is kept
+	Storage storage.Storage
+	// max size in bytes of the uploaded files
+	MaxMediaSize int64
+	// number of open reports that hide a post until a moderator reviews it
+	AutoHideThreshold int
+	// time the uploads that are not attached to a post are kept
+	UnattachedMediaTTL time.Duration
 }
 
 type Server interface {
 	Config() *Config
+	Hub() *websocket.Hub
 }
 
 type Broker struct {
 	config *Config
 	router mux.Router
+	hub *websocket.Hub
 }
 
 func (b *Broker) Config() *Config {
 	return b.config
 }
 
+func (b *Broker) Hub() *websocket.Hub {
+	return b.hub
+}
+
 func NewServer(ctx context.Context, config *Config) (*Broker , error) {
 	if config.Port == "" {
 		return nil, errors.New("port is required")
@@ -45,9 +91,34 @@ func NewServer(ctx context.Context, config *Config) (*Broker , error) {
 		return nil, errors.New("database is required")
 	}
 
+	if config.MaxPostLength == 0 {
+		config.MaxPostLength = DEFAULT_MAX_POST_LENGTH
+	}
+
+	if config.TrashRetention == 0 {
+		config.TrashRetention = DEFAULT_TRASH_RETENTION
+	}
+
+	if config.Storage == nil {
+		return nil, errors.New("storage is required")
+	}
+
+	if config.MaxMediaSize == 0 {
+		config.MaxMediaSize = DEFAULT_MAX_MEDIA_SIZE
+	}
+
+	if config.AutoHideThreshold == 0 {
+		config.AutoHideThreshold = DEFAULT_AUTO_HIDE_THRESHOLD
+	}
+
+	if config.UnattachedMediaTTL == 0 {
+		config.UnattachedMediaTTL = DEFAULT_UNATTACHED_MEDIA_TTL
+	}
+
 	broker := &Broker{
 		config: config,
 		router: *mux.NewRouter(),
+		hub: websocket.NewHub(),
 	}
 
 	return broker, nil
@@ -65,9 +136,133 @@ func (b *Broker) Start(binder func (s Server, r *mux.Router)) {
 	// call the abstract interface in order to instance the "postgres" repo
 	// if need to change to another db then we pass the other db here
 	repository.SetRepository(repo)
+	storage.SetStorage(b.config.Storage)
+
+	// start listening the clients that connect to the websocket
+	b.hub.SetTopicAuthorizer(notify.CanSubscribe)
+	go b.hub.Run()
+	go b.purgeTrash()
+	go b.publishScheduledPosts()
+	go b.closeDuePolls()
+	go b.processMedia()
 
 	log.Println("Starting server on port ", b.Config().Port)
 	if err := http.ListenAndServe(b.config.Port, &b.router); err != nil {
 		log.Fatal("ListenAndServe: ", err)
 	}
-}
\ No newline at end of file
+}
+
+// purgeTrash periodically removes for good the posts that are in the trash for longer than the retention,
+// with the files of their media, and the uploads that were never attached to a post
+func (b *Broker) purgeTrash() {
+	ticker := time.NewTicker(TRASH_PURGE_INTERVAL)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		ctx := context.Background()
+		// deleted_at and created_at are TIMESTAMP written by NOW() in UTC
+		purged, storageKeys, err := repository.PurgeDeletedPosts(ctx, time.Now().UTC().Add(-b.config.TrashRetention))
+		if err != nil {
+			log.Println("Error purging the trash: ", err)
+		} else if purged > 0 {
+			log.Println("Purged posts from the trash: ", purged)
+			deleteObjects(ctx, storageKeys)
+		}
+
+		storageKeys, err = repository.PurgeUnattachedMedia(ctx, time.Now().UTC().Add(-b.config.UnattachedMediaTTL))
+		if err != nil {
+			log.Println("Error purging the unattached media: ", err)
+			continue
+		}
+		if len(storageKeys) > 0 {
+			log.Println("Purged unattached media: ", len(storageKeys))
+			deleteObjects(ctx, storageKeys)
+		}
+	}
+}
+
+// deleteObjects removes the files of the purged media, the rows are already gone so the
+// ones that can't be removed are only logged
+func deleteObjects(ctx context.Context, storageKeys []string) {
+	for _, key := range storageKeys {
+		if err := storage.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Println("Error deleting the media file ", key, ": ", err)
+		}
+	}
+}
+
+// publishScheduledPosts periodically publishes the scheduled posts whose publish_at is due,
+// the rows are locked while they are published so several instances can run it at the same time
+func (b *Broker) publishScheduledPosts() {
+	ticker := time.NewTicker(SCHEDULER_INTERVAL)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		ctx := context.Background()
+		posts, err := repository.PublishDuePosts(ctx, SCHEDULER_BATCH_SIZE)
+		if err != nil {
+			log.Println("Error publishing the scheduled posts: ", err)
+			continue
+		}
+		for _, post := range posts {
+			if err := notify.PostPublished(ctx, b.hub, post); err != nil {
+				log.Println("Error notifying the published post: ", err)
+			}
+		}
+	}
+}
+
+// closeDuePolls periodically closes the polls whose closes_at is due and sends their final tallies,
+// the votes are already rejected since closes_at so it only has to run as often as the scheduler
+func (b *Broker) closeDuePolls() {
+	ticker := time.NewTicker(SCHEDULER_INTERVAL)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		polls, err := repository.CloseDuePolls(context.Background(), POLL_CLOSE_BATCH_SIZE)
+		if err != nil {
+			log.Println("Error closing the polls: ", err)
+			continue
+		}
+		for _, poll := range polls {
+			if err := notify.PollUpdated(context.Background(), b.hub, poll); err != nil {
+				log.Println("Error notifying the closed poll: ", err)
+			}
+		}
+	}
+}
+
+// processMedia periodically generates the variants of the images attached to posts,
+// the images that can't be processed are marked as failed
+func (b *Broker) processMedia() {
+	ticker := time.NewTicker(MEDIA_PROCESSING_INTERVAL)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		ctx := context.Background()
+		pending, err := repository.ClaimPendingMedia(ctx, MEDIA_PROCESSING_BATCH_SIZE, MEDIA_PROCESSING_TIMEOUT)
+		if err != nil {
+			log.Println("Error claiming the media to process: ", err)
+			continue
+		}
+		for _, media := range pending {
+			if err := processMediaItem(ctx, media); err != nil {
+				log.Println("Error processing the media ", media.Id, ": ", err)
+				if err := repository.SetMediaStatus(ctx, media.Id, models.MediaStatusFailed); err != nil {
+					log.Println("Error marking the media as failed: ", err)
+				}
+			}
+		}
+	}
+}
+
+// processMediaItem turns a panic while decoding or resizing a broken image into an error, so the
+// media is marked as failed and the rest of them are still processed
+func processMediaItem(ctx context.Context, media *models.Media) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return imaging.Process(ctx, media)
+}
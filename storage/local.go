@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emavillamayorpsh/rest-ws/apierror"
+)
+
+// LOCAL_URL_PREFIX is the path where the server mounts the Local storage in order to serve its files
+const LOCAL_URL_PREFIX = "/files/"
+
+// Local keeps the objects in a directory of the server, the signed urls point to the server itself
+// which checks the signature with ServeHTTP
+type Local struct {
+	dir     string
+	baseUrl string
+	secret  []byte
+	ttl     time.Duration
+}
+
+// NewLocal creates the directory when it doesn't exist, baseUrl is the public url of the server
+func NewLocal(dir string, baseUrl string, secret string, ttl time.Duration) (*Local, error) {
+	if secret == "" {
+		return nil, errors.New("the secret of the local storage is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{
+		dir:     dir,
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		secret:  []byte(secret),
+		ttl:     ttl,
+	}, nil
+}
+
+// path returns the file of the key, the keys can't go out of the directory
+func (local *Local) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if cleaned == "/" || cleaned != "/"+key {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(local.dir, filepath.FromSlash(cleaned)), nil
+}
+
+// Put writes a temporary file that is renamed at the end, so a failed upload doesn't leave a partial object
+func (local *Local) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	path, err := local.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (local *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := local.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (local *Local) Delete(ctx context.Context, key string) error {
+	path, err := local.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (local *Local) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, local.secret)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (local *Local) SignedURL(key string) (string, time.Time, error) {
+	if _, err := local.path(key); err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(local.ttl).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", local.sign(key, expires))
+
+	return local.baseUrl + LOCAL_URL_PREFIX + key + "?" + query.Encode(), expiresAt, nil
+}
+
+// ServeHTTP serves the files of the signed urls while they haven't expired
+func (local *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, LOCAL_URL_PREFIX)
+	expires := r.URL.Query().Get("expires")
+	signature := r.URL.Query().Get("signature")
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt || !hmac.Equal([]byte(signature), []byte(local.sign(key, expires))) {
+		apierror.Write(w, r, apierror.New(http.StatusForbidden, apierror.CodeForbidden, "The url is invalid or expired"))
+		return
+	}
+
+	notFound := apierror.New(http.StatusNotFound, apierror.CodeNotFound, "The file was not found")
+	path, err := local.path(key)
+	if err != nil {
+		apierror.Write(w, r, notFound)
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		apierror.Write(w, r, notFound.WithCause(err))
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		apierror.Write(w, r, notFound)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	local, err := NewLocal(t.TempDir(), "http://localhost:5050", "signing-key", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return local
+}
+
+// serveSignedURL requests the signed url to the local storage, like the server does for LOCAL_URL_PREFIX
+func serveSignedURL(t *testing.T, local *Local, signedUrl string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	parsed, err := url.Parse(signedUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	local.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, parsed.RequestURI(), nil))
+	return recorder
+}
+
+func TestLocalPutGetDelete(t *testing.T) {
+	local := newTestLocal(t)
+	ctx := context.Background()
+
+	if err := local.Put(ctx, "media/photo.jpg", strings.NewReader("content"), 7, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := readObject(t, local, "media/photo.jpg"); got != "content" {
+		t.Errorf("Get = %q, want %q", got, "content")
+	}
+
+	if err := local.Delete(ctx, "media/photo.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := local.Get(ctx, "media/photo.jpg"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+
+	if err := local.Put(ctx, "../outside.jpg", strings.NewReader("content"), 7, "image/jpeg"); err == nil {
+		t.Error("Put accepted a key out of the directory")
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	local := newTestLocal(t)
+	if err := local.Put(context.Background(), "media/photo.jpg", strings.NewReader("content"), 7, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	signedUrl, expiresAt, err := local.SignedURL("media/photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signedUrl, "http://localhost:5050"+LOCAL_URL_PREFIX+"media/photo.jpg?") {
+		t.Errorf("the url %q isn't served by the server", signedUrl)
+	}
+	if ttl := time.Until(expiresAt); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("the url expires in %s, want an hour", ttl)
+	}
+
+	recorder := serveSignedURL(t, local, signedUrl)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", recorder.Code)
+	}
+	if body, _ := io.ReadAll(recorder.Body); string(body) != "content" {
+		t.Errorf("body = %q, want %q", body, "content")
+	}
+}
+
+func TestLocalSignedURLRejected(t *testing.T) {
+	local := newTestLocal(t)
+	if err := local.Put(context.Background(), "media/photo.jpg", strings.NewReader("content"), 7, "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	signedUrl, _, err := local.SignedURL("media/photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// signed with the right key but already expired
+	expired := strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10)
+	expiredQuery := url.Values{"expires": {expired}, "signature": {local.sign("media/photo.jpg", expired)}}
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"Expired", LOCAL_URL_PREFIX + "media/photo.jpg?" + expiredQuery.Encode()},
+		{"ExtendedExpiration", strings.Replace(signedUrl, "expires=", "expires=9", 1)},
+		{"AnotherKey", strings.Replace(signedUrl, "photo.jpg", "other.jpg", 1)},
+		{"WithoutSignature", strings.Split(signedUrl, "?")[0]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if recorder := serveSignedURL(t, local, test.url); recorder.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want 403", recorder.Code)
+			}
+		})
+	}
+}
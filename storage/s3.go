@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// max expiration of the presigned urls accepted by S3
+	S3_MAX_URL_TTL = 7 * 24 * time.Hour
+)
+
+type S3Config struct {
+	// like https://s3.eu-west-1.amazonaws.com, or the url of a compatible server like MinIO
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// the bucket goes in the path instead of the host, the compatible servers usually need it
+	PathStyle bool
+	UrlTTL    time.Duration
+}
+
+// S3 keeps the objects in a bucket of S3 or of a compatible server, the requests are signed
+// with Signature Version 4 so no SDK is needed
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+	signer   *signer
+	client   *http.Client
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("the endpoint, bucket and keys of the s3 storage are required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.UrlTTL > S3_MAX_URL_TTL {
+		return nil, fmt.Errorf("the urls of the s3 storage can't be valid for more than %s", S3_MAX_URL_TTL)
+	}
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", config.Endpoint)
+	}
+
+	return &S3{
+		config:   config,
+		endpoint: endpoint,
+		signer:   &signer{accessKey: config.AccessKey, secretKey: config.SecretKey, region: config.Region},
+		client:   &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func (s *S3) objectUrl(key string) *url.URL {
+	objectUrl := *s.endpoint
+	path := strings.TrimSuffix(objectUrl.Path, "/")
+	if s.config.PathStyle {
+		objectUrl.Path = path + "/" + s.config.Bucket + "/" + key
+	} else {
+		objectUrl.Host = s.config.Bucket + "." + objectUrl.Host
+		objectUrl.Path = path + "/" + key
+	}
+	return &objectUrl
+}
+
+// send signs and sends the request, the responses that are not 2xx are returned as errors.
+// The caller closes the body of the response
+func (s *S3) send(request *http.Request) (*http.Response, error) {
+	s.signer.signRequest(request, time.Now(), UNSIGNED_PAYLOAD)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrNotFound
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", request.Method, request.URL.Path, response.Status, body)
+	}
+	return response, nil
+}
+
+func (s *S3) do(request *http.Request) error {
+	response, err := s.send(request)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func (s *S3) Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectUrl(key).String(), content)
+	if err != nil {
+		return err
+	}
+	request.ContentLength = size
+	request.Header.Set("Content-Type", contentType)
+	return s.do(request)
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectUrl(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.send(request)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// Delete doesn't fail when the object doesn't exist, S3 answers the same in both cases
+func (s *S3) Delete(ctx context.Context, key string) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectUrl(key).String(), nil)
+	if err != nil {
+		return err
+	}
+	return s.do(request)
+}
+
+func (s *S3) SignedURL(key string) (string, time.Time, error) {
+	now := time.Now().Truncate(time.Second)
+	return s.signer.presignURL(http.MethodGet, s.objectUrl(key), now, s.config.UrlTTL), now.Add(s.config.UrlTTL), nil
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	standInBucket    = "media"
+	standInAccessKey = "access-key"
+	standInSecretKey = "secret-key"
+)
+
+// s3StandIn is a local stand-in of S3 with the path style urls, it checks the signature of the
+// requests with the signed headers of their Authorization and keeps the objects in memory
+type s3StandIn struct {
+	t       *testing.T
+	signer  *signer
+	mu      sync.Mutex
+	objects map[string]string
+	types   map[string]string
+}
+
+func newS3StandIn(t *testing.T) (*s3StandIn, *httptest.Server) {
+	standIn := &s3StandIn{
+		t:       t,
+		signer:  &signer{accessKey: standInAccessKey, secretKey: standInSecretKey, region: "us-east-1"},
+		objects: map[string]string{},
+		types:   map[string]string{},
+	}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+	return standIn, server
+}
+
+// validSignature signs again a copy of the request with only the headers that the client signed
+func (standIn *s3StandIn) validSignature(r *http.Request) bool {
+	authorization := r.Header.Get("Authorization")
+	_, signedHeaders, found := strings.Cut(authorization, "SignedHeaders=")
+	if !found {
+		return false
+	}
+	signedHeaders, _, _ = strings.Cut(signedHeaders, ",")
+
+	now, err := time.Parse(SIGV4_DATE_FORMAT, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	signed, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if err != nil {
+		return false
+	}
+	for _, name := range strings.Split(signedHeaders, ";") {
+		if name != "host" {
+			signed.Header.Set(name, r.Header.Get(name))
+		}
+	}
+	standIn.signer.signRequest(signed, now, r.Header.Get("X-Amz-Content-Sha256"))
+	return signed.Header.Get("Authorization") == authorization
+}
+
+func (standIn *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !standIn.validSignature(r) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != standInBucket || key == "" {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+
+	standIn.mu.Lock()
+	defer standIn.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		content, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(content)) != r.ContentLength {
+			http.Error(w, "IncompleteBody", http.StatusBadRequest)
+			return
+		}
+		standIn.objects[key] = string(content)
+		standIn.types[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		content, ok := standIn.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", standIn.types[key])
+		io.WriteString(w, content)
+	case http.MethodDelete:
+		delete(standIn.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestS3(t *testing.T, endpoint string, secretKey string) *S3 {
+	s3, err := NewS3(S3Config{
+		Endpoint:  endpoint,
+		Bucket:    standInBucket,
+		AccessKey: standInAccessKey,
+		SecretKey: secretKey,
+		PathStyle: true,
+		UrlTTL:    time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s3
+}
+
+func readObject(t *testing.T, storage Storage, key string) string {
+	t.Helper()
+
+	content, err := storage.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%q): %v", key, err)
+	}
+	defer content.Close()
+
+	body, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestS3PutGetDelete(t *testing.T) {
+	standIn, server := newS3StandIn(t)
+	s3 := newTestS3(t, server.URL, standInSecretKey)
+	ctx := context.Background()
+	key := "media/2022/photo one.jpg"
+
+	if err := s3.Put(ctx, key, strings.NewReader("first"), 5, "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s3.Put(ctx, key, strings.NewReader("second"), 6, "image/png"); err != nil {
+		t.Fatalf("Put replacing the object: %v", err)
+	}
+	if got := readObject(t, s3, key); got != "second" {
+		t.Errorf("Get = %q, want %q", got, "second")
+	}
+	if got := standIn.types["media/2022/photo one.jpg"]; got != "image/png" {
+		t.Errorf("the content type stored is %q, want image/png", got)
+	}
+
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s3.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	// S3 answers the same when the object doesn't exist
+	if err := s3.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestS3RejectedSignature(t *testing.T) {
+	_, server := newS3StandIn(t)
+	s3 := newTestS3(t, server.URL, "another-secret-key")
+
+	err := s3.Put(context.Background(), "media/photo.jpg", strings.NewReader("content"), 7, "image/jpeg")
+	if err == nil || errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Put with a wrong secret key = %v, want a 403 error", err)
+	}
+}
+
+func TestS3SignedURL(t *testing.T) {
+	s3 := newTestS3(t, "https://s3.example.com", standInSecretKey)
+
+	signedUrl, expiresAt, err := s3.SignedURL("media/photo.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := time.Until(expiresAt); ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("the url expires in %s, want an hour", ttl)
+	}
+
+	parsed, err := url.Parse(signedUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Path != "/media/media/photo.jpg" {
+		t.Errorf("path = %q, want the bucket and the key", parsed.Path)
+	}
+	query := parsed.Query()
+	if query.Get("X-Amz-Expires") != "3600" || query.Get("X-Amz-Signature") == "" {
+		t.Errorf("the url isn't presigned for an hour: %s", signedUrl)
+	}
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4, only what the S3 storage needs: the Authorization header of the
+// requests done by the server and the query string of the presigned urls.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
+
+const (
+	SIGV4_ALGORITHM    = "AWS4-HMAC-SHA256"
+	SIGV4_DATE_FORMAT  = "20060102T150405Z"
+	UNSIGNED_PAYLOAD   = "UNSIGNED-PAYLOAD"
+	SIGV4_SERVICE_NAME = "s3"
+)
+
+type signer struct {
+	accessKey string
+	secretKey string
+	region    string
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncode escapes everything except the unreserved characters, the slashes are kept in the paths
+func uriEncode(value string, keepSlash bool) string {
+	var builder strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~':
+			builder.WriteByte(b)
+		case b == '/' && keepSlash:
+			builder.WriteByte(b)
+		default:
+			builder.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{b})))
+		}
+	}
+	return builder.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, false)+"="+uriEncode(value, false))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders returns the signed headers and their canonical form, the host is always signed
+func canonicalHeaders(request *http.Request) (string, string) {
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		builder.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return strings.Join(names, ";"), builder.String()
+}
+
+func (s *signer) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.region + "/" + SIGV4_SERVICE_NAME + "/aws4_request"
+}
+
+func (s *signer) signature(now time.Time, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		SIGV4_ALGORITHM,
+		now.Format(SIGV4_DATE_FORMAT),
+		s.scope(now),
+		sha256Hex(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, SIGV4_SERVICE_NAME)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// signRequest adds the Authorization header. The storage sends UNSIGNED_PAYLOAD as payloadHash
+// so the body can be streamed
+func (s *signer) signRequest(request *http.Request, now time.Time, payloadHash string) {
+	now = now.UTC()
+	request.Header.Set("X-Amz-Date", now.Format(SIGV4_DATE_FORMAT))
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders, headers := canonicalHeaders(request)
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		uriEncode(request.URL.Path, true),
+		canonicalQuery(request.URL.Query()),
+		headers,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	request.Header.Set("Authorization", SIGV4_ALGORITHM+
+		" Credential="+s.accessKey+"/"+s.scope(now)+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+s.signature(now, canonicalRequest))
+}
+
+// presignURL returns the url with the signature in the query string, only the host is signed
+func (s *signer) presignURL(method string, objectUrl *url.URL, now time.Time, expires time.Duration) string {
+	now = now.UTC()
+	query := objectUrl.Query()
+	query.Set("X-Amz-Algorithm", SIGV4_ALGORITHM)
+	query.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(SIGV4_DATE_FORMAT))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		uriEncode(objectUrl.Path, true),
+		canonicalQuery(query),
+		"host:" + objectUrl.Host + "\n",
+		"host",
+		UNSIGNED_PAYLOAD,
+	}, "\n")
+
+	signed := *objectUrl
+	signed.RawQuery = canonicalQuery(query) + "&X-Amz-Signature=" + s.signature(now, canonicalRequest)
+	return signed.String()
+}
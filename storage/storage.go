@@ -0,0 +1,45 @@
+// Package storage keeps the content of the uploaded media, the database only has its metadata.
+// Like the repository, the implementation is set once when the server starts
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+type Storage interface {
+	// Put stores the object, replacing it when the key already exists
+	Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error
+	// Get returns ErrNotFound when the object doesn't exist, the caller closes the content
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns an url to download the object without the token of the user, it stops
+	// working at expiresAt
+	SignedURL(key string) (url string, expiresAt time.Time, err error)
+}
+
+var implementation Storage
+
+func SetStorage(storage Storage) {
+	implementation = storage
+}
+
+func Put(ctx context.Context, key string, content io.Reader, size int64, contentType string) error {
+	return implementation.Put(ctx, key, content, size, contentType)
+}
+
+func Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return implementation.Get(ctx, key)
+}
+
+func Delete(ctx context.Context, key string) error {
+	return implementation.Delete(ctx, key)
+}
+
+func SignedURL(key string) (string, time.Time, error) {
+	return implementation.SignedURL(key)
+}
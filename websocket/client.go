@@ -0,0 +1,92 @@
+package websocket
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	OUTBOUND_BUFFER = 32
+)
+
+// messages sent by the clients to choose the topics they want to receive
+type clientCommand struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+}
+
+type Client struct {
+	hub      *Hub
+	id       string
+	userId   string
+	socket   *websocket.Conn
+	outbound chan []byte
+
+	mutex  sync.RWMutex
+	topics map[string]bool
+}
+
+func NewClient(hub *Hub, socket *websocket.Conn, id string, userId string) *Client {
+	return &Client{
+		hub:      hub,
+		id:       id,
+		userId:   userId,
+		socket:   socket,
+		outbound: make(chan []byte, OUTBOUND_BUFFER),
+		topics:   map[string]bool{},
+	}
+}
+
+func (c *Client) UserId() string {
+	return c.userId
+}
+
+func (c *Client) IsSubscribed(topic string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.topics[topic]
+}
+
+// Read receives the subscribe / unsubscribe commands until the connection is closed
+func (c *Client) Read() {
+	defer func() {
+		c.hub.unregister <- c
+	}()
+
+	for {
+		_, data, err := c.socket.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var command clientCommand
+		if err := json.Unmarshal(data, &command); err != nil || command.Topic == "" {
+			continue
+		}
+		// the topics of the posts the user can't see are ignored
+		if command.Type == "subscribe" && !c.hub.canSubscribe(c.userId, command.Topic) {
+			continue
+		}
+
+		c.mutex.Lock()
+		switch command.Type {
+		case "subscribe":
+			c.topics[command.Topic] = true
+		case "unsubscribe":
+			delete(c.topics, command.Topic)
+		}
+		c.mutex.Unlock()
+	}
+}
+
+// Write sends the messages of the hub to the socket
+func (c *Client) Write() {
+	for message := range c.outbound {
+		if err := c.socket.WriteMessage(websocket.TextMessage, message); err != nil {
+			return
+		}
+	}
+	c.socket.WriteMessage(websocket.CloseMessage, []byte{})
+}
@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/emavillamayorpsh/rest-ws/requestctx"
+	"github.com/gorilla/websocket"
+	"github.com/segmentio/ksuid"
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// TopicAuthorizer tells if the user can subscribe to the topic
+type TopicAuthorizer func(userId string, topic string) bool
+
+type Hub struct {
+	clients    []*Client
+	register   chan *Client
+	unregister chan *Client
+	mutex      *sync.Mutex
+	authorize  TopicAuthorizer
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		clients:    make([]*Client, 0),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		mutex:      &sync.Mutex{},
+	}
+}
+
+// HandleWebSocket upgrades the connection, the user is taken from the auth context
+func (hub *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	socket, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	client := NewClient(hub, socket, ksuid.New().String(), requestctx.UserId(r.Context()))
+	hub.register <- client
+
+	go client.Write()
+	go client.Read()
+}
+
+// SetTopicAuthorizer sets the check done when a client subscribes to a topic, without
+// it every subscription is accepted
+func (hub *Hub) SetTopicAuthorizer(authorize TopicAuthorizer) {
+	hub.authorize = authorize
+}
+
+func (hub *Hub) canSubscribe(userId string, topic string) bool {
+	return hub.authorize == nil || hub.authorize(userId, topic)
+}
+
+func (hub *Hub) Run() {
+	for {
+		select {
+		case client := <-hub.register:
+			hub.onConnect(client)
+		case client := <-hub.unregister:
+			hub.onDisconnect(client)
+		}
+	}
+}
+
+func (hub *Hub) onConnect(client *Client) {
+	log.Println("Client connected", client.socket.RemoteAddr())
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	hub.clients = append(hub.clients, client)
+}
+
+func (hub *Hub) onDisconnect(client *Client) {
+	log.Println("Client disconnected", client.socket.RemoteAddr())
+
+	client.socket.Close()
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	for i, c := range hub.clients {
+		if c.id == client.id {
+			hub.clients = append(hub.clients[:i], hub.clients[i+1:]...)
+			close(client.outbound)
+			return
+		}
+	}
+}
+
+// Broadcast sends the message to every client, except ignore
+func (hub *Hub) Broadcast(message interface{}, ignore *Client) {
+	hub.send(message, func(client *Client) bool {
+		return client != ignore
+	})
+}
+
+// BroadcastExcept sends the message to every client, except the ones of the excluded users
+func (hub *Hub) BroadcastExcept(message interface{}, excludedUserIds []string) {
+	excluded := userSet(excludedUserIds)
+	hub.send(message, func(client *Client) bool {
+		return !excluded[client.userId]
+	})
+}
+
+// Publish sends the message only to the clients subscribed to the topic
+func (hub *Hub) Publish(topic string, message interface{}) {
+	hub.send(message, func(client *Client) bool {
+		return client.IsSubscribed(topic)
+	})
+}
+
+// PublishToUsers sends the message to the clients subscribed to the topic, only the ones of the users
+func (hub *Hub) PublishToUsers(topic string, message interface{}, userIds []string) {
+	users := userSet(userIds)
+	hub.send(message, func(client *Client) bool {
+		return users[client.userId] && client.IsSubscribed(topic)
+	})
+}
+
+// TopicUserIds returns the users with a client subscribed to the topic, each one once
+func (hub *Hub) TopicUserIds(topic string) []string {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	users := map[string]bool{}
+	var userIds []string
+	for _, client := range hub.clients {
+		if !users[client.userId] && client.IsSubscribed(topic) {
+			users[client.userId] = true
+			userIds = append(userIds, client.userId)
+		}
+	}
+	return userIds
+}
+
+// SendToUsers sends the message to every connection of the users
+func (hub *Hub) SendToUsers(userIds []string, message interface{}) {
+	users := userSet(userIds)
+	hub.send(message, func(client *Client) bool {
+		return users[client.userId]
+	})
+}
+
+func userSet(userIds []string) map[string]bool {
+	users := make(map[string]bool, len(userIds))
+	for _, userId := range userIds {
+		users[userId] = true
+	}
+	return users
+}
+
+// SendToUser sends the message to every connection of the user
+func (hub *Hub) SendToUser(userId string, message interface{}) {
+	hub.send(message, func(client *Client) bool {
+		return client.userId == userId
+	})
+}
+
+func (hub *Hub) send(message interface{}, accept func(client *Client) bool) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	for _, client := range hub.clients {
+		if !accept(client) {
+			continue
+		}
+		// slow clients lose messages instead of blocking the rest of them
+		select {
+		case client.outbound <- data:
+		default:
+			log.Println("Dropping message for slow client", client.id)
+		}
+	}
+}